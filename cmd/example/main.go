@@ -19,6 +19,8 @@ func main() {
 		privateFile  = flag.String("private-key", "private.pem", "Path to private key file")
 		publicFile   = flag.String("public-key", "public.pem", "Path to public key file")
 		keyBits      = flag.Int("key-bits", 2048, "RSA key size (2048 or 4096)")
+		keyType      = flag.String("key-type", "rsa", "Key type (rsa, ecdsa or ed25519)")
+		algorithm    = flag.String("algorithm", "", "Signature algorithm (RS256, PS256, ES256 or EdDSA; default: chosen by key type)")
 		baseURL      = flag.String("url", "", "Cloudflare Worker base URL")
 		clientID     = flag.String("client-id", "testclient", "Client ID")
 		maxRetries   = flag.Int("retries", 0, "Maximum number of retries")
@@ -43,10 +45,10 @@ func main() {
 			os.Exit(1)
 		}
 
-		fmt.Println("Generating RSA key pair...")
+		fmt.Printf("Generating %s key pair...\n", *keyType)
 
 		// 鍵ペアとCloudflare設定ファイルを生成
-		if err := keygen.GenerateAndSaveKeyPair(*privateFile, *publicFile, *clientID, *keyBits); err != nil {
+		if err := keygen.GenerateAndSaveSignerKeyPair(*privateFile, *publicFile, *clientID, *keyType, *keyBits); err != nil {
 			log.Fatalf("Failed to generate key pair: %v", err)
 		}
 
@@ -105,7 +107,7 @@ func main() {
 	}
 
 	// 秘密鍵を読み込み
-	privateKey, err := keygen.LoadPrivateKey(*privateFile)
+	signer, err := keygen.LoadSigner(*privateFile)
 	if err != nil {
 		log.Fatalf("Failed to load private key: %v", err)
 	}
@@ -123,7 +125,8 @@ func main() {
 	client, err := authclient.NewClient(authclient.ClientConfig{
 		BaseURL:         *baseURL,
 		ClientID:        *clientID,
-		PrivateKey:      privateKey,
+		Signer:          signer,
+		Algorithm:       *algorithm,
 		SecretKeys:      secretKeyList,
 		RepoUrl:         *repoUrl,
 		GrpcEndpoint:    *grpcEndpoint,
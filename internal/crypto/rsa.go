@@ -2,7 +2,6 @@ package crypto
 
 import (
 	"crypto"
-	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/base64"
@@ -16,23 +15,14 @@ func SignChallenge(privateKey *rsa.PrivateKey, challenge string) (string, error)
 		return "", fmt.Errorf("private key is nil")
 	}
 
-	if challenge == "" {
-		return "", fmt.Errorf("challenge is empty")
-	}
-
-	// チャレンジをSHA-256でハッシュ化
-	hashed := sha256.Sum256([]byte(challenge))
-
-	// RSASSA-PKCS1-v1_5で署名
-	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hashed[:])
-	if err != nil {
-		return "", fmt.Errorf("failed to sign challenge: %w", err)
-	}
-
-	// Base64エンコード
-	signatureBase64 := base64.StdEncoding.EncodeToString(signature)
+	return SignChallengeWithSigner(privateKey, challenge)
+}
 
-	return signatureBase64, nil
+// SignChallengeWithSigner はcrypto.Signerでチャレンジに署名してBase64エンコードした文字列を返します
+// 秘密鍵をメモリ上に持たない署名器（エージェント、HSMなど）でも使用できます
+// RSASSA-PKCS1-v1_5 + SHA-256を使用するため、署名器はRSA鍵である必要があります
+func SignChallengeWithSigner(signer crypto.Signer, challenge string) (string, error) {
+	return SignChallengeWithAlgorithm(signer, AlgorithmRS256, challenge)
 }
 
 // VerifySignature は署名を検証します（テスト用）
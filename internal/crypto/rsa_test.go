@@ -1,8 +1,12 @@
 package crypto
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"io"
 	"testing"
 )
 
@@ -168,3 +172,52 @@ func TestSignatureIsBase64(t *testing.T) {
 		t.Errorf("signature is not valid base64: %v", err)
 	}
 }
+
+// opaqueSigner は秘密鍵を公開しないcrypto.Signer（HSMやエージェントを模擬）
+type opaqueSigner struct {
+	key   *rsa.PrivateKey
+	calls int
+}
+
+func (s *opaqueSigner) Public() crypto.PublicKey {
+	return &s.key.PublicKey
+}
+
+func (s *opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.calls++
+	return s.key.Sign(rand, digest, opts)
+}
+
+func TestSignChallengeWithSigner(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+	signer := &opaqueSigner{key: privateKey}
+
+	challenge := "signer-challenge"
+	signature, err := SignChallengeWithSigner(signer, challenge)
+	if err != nil {
+		t.Fatalf("SignChallengeWithSigner() failed: %v", err)
+	}
+	if signer.calls != 1 {
+		t.Errorf("Sign() called %d times, want 1", signer.calls)
+	}
+
+	// 既存のRSA検証と互換性がある
+	if err := VerifySignature(&privateKey.PublicKey, challenge, signature); err != nil {
+		t.Errorf("VerifySignature() failed: %v", err)
+	}
+
+	if _, err := SignChallengeWithSigner(nil, challenge); err == nil {
+		t.Error("SignChallengeWithSigner(nil) error = nil, want error")
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ecdsa key: %v", err)
+	}
+	if _, err := SignChallengeWithSigner(ecKey, challenge); err == nil {
+		t.Error("SignChallengeWithSigner(ecdsa) error = nil, want unsupported key error")
+	}
+}
@@ -0,0 +1,195 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+// Algorithm は署名アルゴリズムの識別子（JWSのalg値と同じ表記）
+type Algorithm string
+
+const (
+	// AlgorithmRS256 はRSASSA-PKCS1-v1_5 + SHA-256
+	AlgorithmRS256 Algorithm = "RS256"
+
+	// AlgorithmPS256 はRSASSA-PSS + SHA-256（ソルト長はハッシュ長と同じ32バイト）
+	AlgorithmPS256 Algorithm = "PS256"
+
+	// AlgorithmES256 はECDSA P-256 + SHA-256（署名はr||sの64バイト）
+	AlgorithmES256 Algorithm = "ES256"
+
+	// AlgorithmEdDSA はEd25519
+	AlgorithmEdDSA Algorithm = "EdDSA"
+)
+
+// es256KeySize はP-256の座標のバイト長
+const es256KeySize = 32
+
+// AlgorithmForKey は公開鍵の型からデフォルトの署名アルゴリズムを決定します
+// RSA鍵は後方互換性のためRS256になります
+func AlgorithmForKey(publicKey crypto.PublicKey) (Algorithm, error) {
+	switch pub := publicKey.(type) {
+	case *rsa.PublicKey:
+		return AlgorithmRS256, nil
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return "", fmt.Errorf("unsupported ecdsa curve: %s", pub.Curve.Params().Name)
+		}
+		return AlgorithmES256, nil
+	case ed25519.PublicKey:
+		return AlgorithmEdDSA, nil
+	default:
+		return "", fmt.Errorf("unsupported public key type: %T", publicKey)
+	}
+}
+
+// CheckAlgorithm は署名アルゴリズムが公開鍵の型と適合するか確認します
+func CheckAlgorithm(publicKey crypto.PublicKey, alg Algorithm) error {
+	switch alg {
+	case AlgorithmRS256, AlgorithmPS256:
+		if _, ok := publicKey.(*rsa.PublicKey); ok {
+			return nil
+		}
+	case AlgorithmES256, AlgorithmEdDSA:
+		if keyAlg, err := AlgorithmForKey(publicKey); err == nil && keyAlg == alg {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm: %q", alg)
+	}
+
+	return fmt.Errorf("algorithm %s cannot be used with %T", alg, publicKey)
+}
+
+// SignChallengeWithAlgorithm は指定したアルゴリズムでチャレンジに署名し、
+// Base64エンコードした文字列を返します
+func SignChallengeWithAlgorithm(signer crypto.Signer, alg Algorithm, challenge string) (string, error) {
+	if signer == nil {
+		return "", fmt.Errorf("signer is nil")
+	}
+
+	if challenge == "" {
+		return "", fmt.Errorf("challenge is empty")
+	}
+
+	if err := CheckAlgorithm(signer.Public(), alg); err != nil {
+		return "", err
+	}
+
+	var (
+		signature []byte
+		err       error
+	)
+
+	switch alg {
+	case AlgorithmEdDSA:
+		// Ed25519はメッセージ全体に署名する
+		signature, err = signer.Sign(rand.Reader, []byte(challenge), crypto.Hash(0))
+	case AlgorithmPS256:
+		hashed := sha256.Sum256([]byte(challenge))
+		signature, err = signer.Sign(rand.Reader, hashed[:], &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+			Hash:       crypto.SHA256,
+		})
+	case AlgorithmES256:
+		hashed := sha256.Sum256([]byte(challenge))
+		var der []byte
+		der, err = signer.Sign(rand.Reader, hashed[:], crypto.SHA256)
+		if err == nil {
+			signature, err = ecdsaDERToRaw(der)
+		}
+	default:
+		// RS256: RSA鍵のSignerはハッシュ関数のみ指定するとPKCS1-v1_5で署名する
+		hashed := sha256.Sum256([]byte(challenge))
+		signature, err = signer.Sign(rand.Reader, hashed[:], crypto.SHA256)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to sign challenge: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// VerifySignatureWithAlgorithm は指定したアルゴリズムで署名を検証します
+func VerifySignatureWithAlgorithm(publicKey crypto.PublicKey, alg Algorithm, challenge string, signatureBase64 string) error {
+	if publicKey == nil {
+		return fmt.Errorf("public key is nil")
+	}
+
+	if challenge == "" {
+		return fmt.Errorf("challenge is empty")
+	}
+
+	if signatureBase64 == "" {
+		return fmt.Errorf("signature is empty")
+	}
+
+	if err := CheckAlgorithm(publicKey, alg); err != nil {
+		return err
+	}
+
+	// Base64デコード
+	signature, err := base64.StdEncoding.DecodeString(signatureBase64)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	hashed := sha256.Sum256([]byte(challenge))
+
+	switch alg {
+	case AlgorithmEdDSA:
+		if !ed25519.Verify(publicKey.(ed25519.PublicKey), []byte(challenge), signature) {
+			return fmt.Errorf("signature verification failed: ed25519: invalid signature")
+		}
+	case AlgorithmPS256:
+		err = rsa.VerifyPSS(publicKey.(*rsa.PublicKey), crypto.SHA256, hashed[:], signature, &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+			Hash:       crypto.SHA256,
+		})
+	case AlgorithmES256:
+		if len(signature) != 2*es256KeySize {
+			return fmt.Errorf("signature verification failed: invalid ES256 signature length %d", len(signature))
+		}
+		r := new(big.Int).SetBytes(signature[:es256KeySize])
+		s := new(big.Int).SetBytes(signature[es256KeySize:])
+		if !ecdsa.Verify(publicKey.(*ecdsa.PublicKey), hashed[:], r, s) {
+			return fmt.Errorf("signature verification failed: ecdsa: invalid signature")
+		}
+	default:
+		err = rsa.VerifyPKCS1v15(publicKey.(*rsa.PublicKey), crypto.SHA256, hashed[:], signature)
+	}
+	if err != nil {
+		return fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	return nil
+}
+
+// ecdsaDERToRaw はASN.1 DER形式のECDSA署名をWeb Crypto互換のr||s形式に変換します
+func ecdsaDERToRaw(der []byte) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("failed to parse ecdsa signature: %w", err)
+	}
+	// P-256以外の鍵や不正なSignerの署名はr・sが32バイトを超え、FillBytesがpanicするため拒否する
+	if sig.R == nil || sig.S == nil || sig.R.Sign() <= 0 || sig.S.Sign() <= 0 ||
+		sig.R.BitLen() > 8*es256KeySize || sig.S.BitLen() > 8*es256KeySize {
+		return nil, fmt.Errorf("invalid ecdsa signature: r and s must be positive and at most %d bytes", es256KeySize)
+	}
+
+	raw := make([]byte, 2*es256KeySize)
+	sig.R.FillBytes(raw[:es256KeySize])
+	sig.S.FillBytes(raw[es256KeySize:])
+	return raw, nil
+}
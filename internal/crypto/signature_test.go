@@ -0,0 +1,180 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"testing"
+)
+
+func TestSignAndVerifyWithAlgorithm(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ecdsa key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ed25519 key: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		signer crypto.Signer
+		alg    Algorithm
+	}{
+		{name: "RS256", signer: rsaKey, alg: AlgorithmRS256},
+		{name: "PS256", signer: rsaKey, alg: AlgorithmPS256},
+		{name: "ES256", signer: ecKey, alg: AlgorithmES256},
+		{name: "EdDSA", signer: edKey, alg: AlgorithmEdDSA},
+	}
+
+	challenge := "algorithm-test-challenge"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signature, err := SignChallengeWithAlgorithm(tt.signer, tt.alg, challenge)
+			if err != nil {
+				t.Fatalf("SignChallengeWithAlgorithm() error = %v", err)
+			}
+
+			if err := VerifySignatureWithAlgorithm(tt.signer.Public(), tt.alg, challenge, signature); err != nil {
+				t.Errorf("VerifySignatureWithAlgorithm() error = %v", err)
+			}
+
+			if err := VerifySignatureWithAlgorithm(tt.signer.Public(), tt.alg, "other-challenge", signature); err == nil {
+				t.Error("VerifySignatureWithAlgorithm() with wrong challenge error = nil, want error")
+			}
+		})
+	}
+
+	// ES256の署名はWeb Crypto互換のr||s形式（64バイト）
+	signature, err := SignChallengeWithAlgorithm(ecKey, AlgorithmES256, challenge)
+	if err != nil {
+		t.Fatalf("SignChallengeWithAlgorithm() error = %v", err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(signature)
+	if len(raw) != 64 {
+		t.Errorf("ES256 signature length = %d, want 64", len(raw))
+	}
+
+	// RS256の署名はPS256として検証できない
+	rs256, err := SignChallengeWithAlgorithm(rsaKey, AlgorithmRS256, challenge)
+	if err != nil {
+		t.Fatalf("SignChallengeWithAlgorithm() error = %v", err)
+	}
+	if err := VerifySignatureWithAlgorithm(&rsaKey.PublicKey, AlgorithmPS256, challenge, rs256); err == nil {
+		t.Error("VerifySignatureWithAlgorithm(PS256) accepted RS256 signature")
+	}
+}
+
+func TestAlgorithmForKey(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name    string
+		key     crypto.PublicKey
+		want    Algorithm
+		wantErr bool
+	}{
+		{name: "rsa", key: &rsaKey.PublicKey, want: AlgorithmRS256},
+		{name: "ecdsa p256", key: &p256.PublicKey, want: AlgorithmES256},
+		{name: "ecdsa p384", key: &p384.PublicKey, wantErr: true},
+		{name: "ed25519", key: edPub, want: AlgorithmEdDSA},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AlgorithmForKey(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AlgorithmForKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("AlgorithmForKey() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAlgorithm(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err := CheckAlgorithm(&rsaKey.PublicKey, AlgorithmPS256); err != nil {
+		t.Errorf("CheckAlgorithm(rsa, PS256) error = %v", err)
+	}
+	if err := CheckAlgorithm(&rsaKey.PublicKey, AlgorithmES256); err == nil {
+		t.Error("CheckAlgorithm(rsa, ES256) error = nil, want error")
+	}
+	if err := CheckAlgorithm(&ecKey.PublicKey, AlgorithmEdDSA); err == nil {
+		t.Error("CheckAlgorithm(ecdsa, EdDSA) error = nil, want error")
+	}
+	if err := CheckAlgorithm(&ecKey.PublicKey, "HS256"); err == nil {
+		t.Error("CheckAlgorithm(ecdsa, HS256) error = nil, want error")
+	}
+}
+
+// mismatchedSigner はP-256の公開鍵を返しながら別の曲線の鍵で署名する不正なcrypto.Signer
+type mismatchedSigner struct {
+	public *ecdsa.PublicKey
+	signer *ecdsa.PrivateKey
+}
+
+func (s *mismatchedSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+func (s *mismatchedSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.signer.Sign(rand, digest, opts)
+}
+
+func TestSignChallengeWithAlgorithm_OversizedECDSASignature(t *testing.T) {
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ecdsa key: %v", err)
+	}
+	p521Key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ecdsa key: %v", err)
+	}
+
+	signer := &mismatchedSigner{public: &p256Key.PublicKey, signer: p521Key}
+	if _, err := SignChallengeWithAlgorithm(signer, AlgorithmES256, "challenge"); err == nil {
+		t.Error("SignChallengeWithAlgorithm() with oversized signature should fail")
+	}
+}
+
+func TestECDSADERToRaw_RejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name string
+		r, s *big.Int
+	}{
+		{name: "zero r", r: big.NewInt(0), s: big.NewInt(1)},
+		{name: "negative s", r: big.NewInt(1), s: big.NewInt(-1)},
+		{name: "oversized r", r: new(big.Int).Lsh(big.NewInt(1), 256), s: big.NewInt(1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der, err := asn1.Marshal(struct{ R, S *big.Int }{tt.r, tt.s})
+			if err != nil {
+				t.Fatalf("failed to marshal signature: %v", err)
+			}
+			if _, err := ecdsaDERToRaw(der); err == nil {
+				t.Error("ecdsaDERToRaw() error = nil, want error")
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package authclient
 
 import (
+	gocrypto "crypto"
 	"fmt"
+	"log/slog"
 
 	"github.com/yhonda-ohishi-pub-dev/go_auth/internal/crypto"
 	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/keygen"
@@ -15,20 +17,79 @@ func LoadPrivateKeyFromFile(filename string) (*Client, error) {
 	}
 
 	return &Client{
-		privateKey: privateKey,
+		signer: privateKey,
+		logger: slog.New(slog.DiscardHandler),
 	}, nil
 }
 
+// 署名アルゴリズムの識別子（ClientConfig.Algorithmに指定）
+const (
+	// AlgorithmRS256 はRSASSA-PKCS1-v1_5 + SHA-256（RSA鍵のデフォルト）
+	AlgorithmRS256 = string(crypto.AlgorithmRS256)
+
+	// AlgorithmPS256 はRSASSA-PSS + SHA-256
+	AlgorithmPS256 = string(crypto.AlgorithmPS256)
+
+	// AlgorithmES256 はECDSA P-256 + SHA-256（ECDSA鍵のデフォルト）
+	AlgorithmES256 = string(crypto.AlgorithmES256)
+
+	// AlgorithmEdDSA はEd25519（Ed25519鍵のデフォルト）
+	AlgorithmEdDSA = string(crypto.AlgorithmEdDSA)
+)
+
+// PublicKey は署名に使用する鍵の公開鍵を返します
+func (c *Client) PublicKey() gocrypto.PublicKey {
+	if c.signer == nil {
+		return nil
+	}
+	return c.signer.Public()
+}
+
+// signingAlgorithm は署名に使用するアルゴリズムを返します
+func (c *Client) signingAlgorithm() (crypto.Algorithm, error) {
+	if c.signer == nil {
+		return "", ErrInvalidPrivateKey
+	}
+	if c.algorithm != "" {
+		return c.algorithm, nil
+	}
+
+	alg, err := crypto.AlgorithmForKey(c.signer.Public())
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPrivateKey, err)
+	}
+	return alg, nil
+}
+
 // signChallenge はチャレンジに署名します
 func (c *Client) signChallenge(challenge string) (string, error) {
-	if c.privateKey == nil {
-		return "", ErrInvalidPrivateKey
+	alg, err := c.signingAlgorithm()
+	if err != nil {
+		return "", err
 	}
 
-	signature, err := crypto.SignChallenge(c.privateKey, challenge)
+	signature, err := crypto.SignChallengeWithAlgorithm(c.signer, alg, challenge)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign challenge: %w", err)
 	}
 
 	return signature, nil
 }
+
+// resolveAlgorithm は設定と鍵の型から署名アルゴリズムを決定します
+// 指定がない場合は鍵の型から選択します（RSA: RS256、ECDSA P-256: ES256、Ed25519: EdDSA）
+func resolveAlgorithm(signer gocrypto.Signer, configured string) (crypto.Algorithm, error) {
+	if configured == "" {
+		alg, err := crypto.AlgorithmForKey(signer.Public())
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidPrivateKey, err)
+		}
+		return alg, nil
+	}
+
+	alg := crypto.Algorithm(configured)
+	if err := crypto.CheckAlgorithm(signer.Public(), alg); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return alg, nil
+}
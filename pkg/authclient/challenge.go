@@ -0,0 +1,54 @@
+package authclient
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxChallengeAttempts は期限切れ時にチャレンジを取り直す最大回数
+const maxChallengeAttempts = 3
+
+// defaultClockSkew はチャレンジ有効期限の判定で許容する時計のずれ
+const defaultClockSkew = 5 * time.Second
+
+// Expired はチャレンジが期限切れかどうかを判定します
+// skewはWorkerとの時計のずれとして許容する時間です
+// ExpiresAtが0の場合は期限なしとみなします
+func (r *ChallengeResponse) Expired(now time.Time, skew time.Duration) bool {
+	if r.ExpiresAt == 0 {
+		return false
+	}
+	return now.After(time.Unix(r.ExpiresAt, 0).Add(skew))
+}
+
+// classifyVerifyError はWorkerの検証エラーをチャレンジ期限切れ・署名不正に分類します
+func classifyVerifyError(err error) error {
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return err
+	}
+
+	if cause := verifyFailureCause(httpErr.Message); cause != nil {
+		if httpErr.Err != nil {
+			httpErr.Err = fmt.Errorf("%w: %w", httpErr.Err, cause)
+		} else {
+			httpErr.Err = cause
+		}
+	}
+	return err
+}
+
+// verifyFailureCause はWorkerのエラーメッセージから失敗の原因を判定します
+func verifyFailureCause(message string) error {
+	message = strings.ToLower(message)
+	switch {
+	case strings.Contains(message, "expired"):
+		return ErrChallengeExpired
+	case strings.Contains(message, "signature"):
+		return ErrInvalidSignature
+	default:
+		return nil
+	}
+}
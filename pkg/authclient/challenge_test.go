@@ -0,0 +1,143 @@
+package authclient
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChallengeResponse_Expired(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name      string
+		expiresAt int64
+		skew      time.Duration
+		want      bool
+	}{
+		{name: "not expired", expiresAt: now.Add(time.Minute).Unix(), want: false},
+		{name: "expired", expiresAt: now.Add(-time.Minute).Unix(), want: true},
+		{name: "within skew", expiresAt: now.Add(-3 * time.Second).Unix(), skew: 5 * time.Second, want: false},
+		{name: "beyond skew", expiresAt: now.Add(-10 * time.Second).Unix(), skew: 5 * time.Second, want: true},
+		{name: "no expiry", expiresAt: 0, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &ChallengeResponse{Challenge: "c", ExpiresAt: tt.expiresAt}
+			if got := resp.Expired(now, tt.skew); got != tt.want {
+				t.Errorf("Expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_RefetchesExpiredChallenge(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	// 最初のチャレンジは既に期限切れ
+	var challenges, verifies atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/challenge", func(w http.ResponseWriter, r *http.Request) {
+		expiresAt := time.Now().Add(time.Minute)
+		if challenges.Add(1) == 1 {
+			expiresAt = time.Now().Add(-time.Hour)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ChallengeResponse{Challenge: "test-challenge", ExpiresAt: expiresAt.Unix()})
+	})
+	mux.HandleFunc("/verify", func(w http.ResponseWriter, r *http.Request) {
+		verifies.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(VerifyResponse{Success: true, AccessToken: "token"})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:    server.URL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if got := challenges.Load(); got != 2 {
+		t.Errorf("/challenge called %d times, want 2", got)
+	}
+	if got := verifies.Load(); got != 1 {
+		t.Errorf("/verify called %d times, want 1 (stale challenge must not be sent)", got)
+	}
+}
+
+func TestAuthenticate_WorkerChallengeExpired(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		message string
+		wantErr error
+		wantNot error
+	}{
+		{name: "expired challenge", message: "Challenge expired", wantErr: ErrChallengeExpired, wantNot: ErrInvalidSignature},
+		{name: "invalid signature", message: "Invalid signature", wantErr: ErrInvalidSignature, wantNot: ErrChallengeExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var challenges atomic.Int32
+			mux := http.NewServeMux()
+			mux.HandleFunc("/challenge", func(w http.ResponseWriter, r *http.Request) {
+				challenges.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(ChallengeResponse{
+					Challenge: "test-challenge",
+					ExpiresAt: time.Now().Add(time.Minute).Unix(),
+				})
+			})
+			mux.HandleFunc("/verify", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(ErrorResponse{Error: tt.message})
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			client, err := NewClient(ClientConfig{
+				BaseURL:    server.URL,
+				ClientID:   "test-client",
+				PrivateKey: privateKey,
+			})
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+
+			_, err = client.Authenticate()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if errors.Is(err, tt.wantNot) {
+				t.Errorf("Authenticate() error = %v, must not match %v", err, tt.wantNot)
+			}
+			if !errors.Is(err, ErrUnauthorized) {
+				t.Errorf("Authenticate() error = %v, want to also match ErrUnauthorized", err)
+			}
+		})
+	}
+}
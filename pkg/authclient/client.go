@@ -2,47 +2,104 @@ package authclient
 
 import (
 	"bytes"
-	"crypto/rsa"
+	"context"
+	"crypto"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"strings"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
 	"time"
 
+	internalcrypto "github.com/yhonda-ohishi-pub-dev/go_auth/internal/crypto"
 	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/keygen"
 )
 
 // Client はCloudflare Auth Workerに接続するクライアント
+// 複数のgoroutineから同時に使用できます
 type Client struct {
-	baseURL         string
-	clientID        string
-	privateKey      *rsa.PrivateKey
-	httpClient      *http.Client
-	timeout         time.Duration
-	maxRetries      int
-	retryBackoff    time.Duration
-	secretKeys      []string
-	repoUrl         string
-	grpcEndpoint    string
-	includeRepoList bool
-	tunnelUrl       string
-	accessToken     string // 認証後に保存されるアクセストークン
+	endpoints        *endpointSet
+	clientID         string
+	signer           crypto.Signer
+	algorithm        internalcrypto.Algorithm
+	httpClient       *http.Client
+	timeout          time.Duration
+	clockSkew        time.Duration
+	logger           *slog.Logger
+	pins             spkiPins
+	secretCache      *secretCache
+	metrics          *clientMetrics
+	hooks            Hooks
+	maxResponseBytes int64
+	strictResponses  bool
+	secretKeys       []string
+	repoUrl          string
+	grpcEndpoint     string
+	includeRepoList  bool
+
+	mu           sync.RWMutex // 以下のフィールドを保護
+	retryPolicy  RetryPolicy
+	tunnelUrl    string
+	accessToken  string    // 認証後に保存されるアクセストークン
+	tokenExpires time.Time // アクセストークンの有効期限（不明な場合はゼロ値）
+
+	flightMu sync.Mutex
+	flight   *authFlight // 実行中の認証（同時呼び出しで共有）
+}
+
+// authFlight は実行中の認証フローとその結果
+type authFlight struct {
+	done chan struct{}
+	resp *VerifyResponse
+	err  error
 }
 
 // NewClient は新しいクライアントを作成します
 func NewClient(config ClientConfig) (*Client, error) {
-	if config.BaseURL == "" {
+	if config.BaseURL != "" && len(config.BaseURLs) > 0 {
+		return nil, fmt.Errorf("%w: only one of baseURL and baseURLs may be set", ErrInvalidConfig)
+	}
+
+	baseURLs := config.BaseURLs
+	if config.BaseURL != "" {
+		baseURLs = []string{config.BaseURL}
+	}
+	if len(baseURLs) == 0 {
 		return nil, fmt.Errorf("%w: baseURL is required", ErrInvalidConfig)
 	}
+	for _, baseURL := range baseURLs {
+		if baseURL == "" {
+			return nil, fmt.Errorf("%w: baseURLs must not contain empty URLs", ErrInvalidConfig)
+		}
+	}
 
 	if config.ClientID == "" {
 		return nil, fmt.Errorf("%w: clientID is required", ErrInvalidConfig)
 	}
 
-	if config.PrivateKey == nil {
-		return nil, fmt.Errorf("%w: privateKey is required", ErrInvalidConfig)
+	if config.PrivateKey != nil && config.Signer != nil {
+		return nil, fmt.Errorf("%w: only one of privateKey and signer may be set", ErrInvalidConfig)
+	}
+
+	// 署名器を決定（RSA秘密鍵がデフォルト）
+	var signer crypto.Signer
+	switch {
+	case config.PrivateKey != nil:
+		signer = config.PrivateKey
+	case config.Signer != nil:
+		signer = config.Signer
+	default:
+		return nil, fmt.Errorf("%w: privateKey or signer is required", ErrInvalidConfig)
+	}
+
+	// 署名アルゴリズムを決定
+	algorithm, err := resolveAlgorithm(signer, config.Algorithm)
+	if err != nil {
+		return nil, err
 	}
 
 	// デフォルトのHTTPクライアントを使用
@@ -58,45 +115,114 @@ func NewClient(config ClientConfig) (*Client, error) {
 	}
 	httpClient.Timeout = timeout
 
-	// HTTPSチェック
-	if !strings.HasPrefix(config.BaseURL, "https://") && !strings.HasPrefix(config.BaseURL, "http://localhost") {
-		fmt.Fprintf(io.Discard, "WARNING: BaseURL is not HTTPS: %s\n", config.BaseURL)
+	// チャレンジ有効期限の判定で許容する時計のずれ
+	clockSkew := config.ClockSkew
+	if clockSkew == 0 {
+		clockSkew = defaultClockSkew
+	}
+
+	// デフォルトはリトライなし
+	retryPolicy := config.RetryPolicy
+	if retryPolicy == nil {
+		retryPolicy = &ExponentialBackoff{}
+	}
+
+	// ロガー未指定の場合は出力しない
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+
+	// HTTPSチェック（StrictTransportの場合はループバック以外のHTTPを拒否）
+	for _, baseURL := range baseURLs {
+		insecure, err := checkBaseURL(baseURL, config.StrictTransport)
+		if err != nil {
+			return nil, err
+		}
+		if insecure {
+			logger.Warn("base URL is not HTTPS", "base_url", baseURL)
+		}
+	}
+
+	// Workerの証明書のピン留め
+	pins, err := newSPKIPins(config.PinnedSPKIHashes)
+	if err != nil {
+		return nil, err
+	}
+	if pins != nil {
+		httpClient = pinnedHTTPClient(httpClient, pins)
+	}
+
+	// レスポンスボディの最大サイズ
+	maxResponseBytes := config.MaxResponseBytes
+	if maxResponseBytes <= 0 {
+		maxResponseBytes = defaultMaxResponseBytes
 	}
 
-	return &Client{
-		baseURL:         strings.TrimSuffix(config.BaseURL, "/"),
-		clientID:        config.ClientID,
-		privateKey:      config.PrivateKey,
-		httpClient:      httpClient,
-		timeout:         timeout,
-		maxRetries:      0, // デフォルトはリトライなし
-		retryBackoff:    2 * time.Second,
-		secretKeys:      config.SecretKeys,
-		repoUrl:         config.RepoUrl,
-		grpcEndpoint:    config.GrpcEndpoint,
-		includeRepoList: config.IncludeRepoList,
-		tunnelUrl:       config.TunnelUrl,
-	}, nil
+	// オフラインキャッシュ
+	var cache *secretCache
+	if config.SecretCache != nil {
+		cache, err = newSecretCache(config.SecretCache, signer, config.ClientID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client := &Client{
+		endpoints:        newEndpointSet(baseURLs, config.EndpointCooldown),
+		clientID:         config.ClientID,
+		signer:           signer,
+		algorithm:        algorithm,
+		httpClient:       httpClient,
+		timeout:          timeout,
+		clockSkew:        clockSkew,
+		logger:           logger,
+		pins:             pins,
+		secretCache:      cache,
+		metrics:          newClientMetrics(config.Metrics),
+		hooks:            config.Hooks,
+		maxResponseBytes: maxResponseBytes,
+		strictResponses:  config.StrictResponses,
+		retryPolicy:      retryPolicy,
+		secretKeys:       config.SecretKeys,
+		repoUrl:          config.RepoUrl,
+		grpcEndpoint:     config.GrpcEndpoint,
+		includeRepoList:  config.IncludeRepoList,
+		tunnelUrl:        config.TunnelUrl,
+	}
+	return client, nil
 }
 
 // NewClientFromFile はファイルから秘密鍵を読み込んでクライアントを作成します
+// RSA・ECDSA P-256・Ed25519のいずれの鍵にも対応しています
 func NewClientFromFile(baseURL, clientID, privateKeyFile string) (*Client, error) {
-	privateKey, err := keygen.LoadPrivateKey(privateKeyFile)
+	signer, err := keygen.LoadSigner(privateKeyFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load private key: %w", err)
 	}
 
 	return NewClient(ClientConfig{
-		BaseURL:    baseURL,
-		ClientID:   clientID,
-		PrivateKey: privateKey,
+		BaseURL:  baseURL,
+		ClientID: clientID,
+		Signer:   signer,
 	})
 }
 
-// SetRetry はリトライ設定を行います
+// SetRetry は固定間隔のリトライ設定を行います
+// backoffが0の場合は待機せずにリトライします
 func (c *Client) SetRetry(maxRetries int, backoff time.Duration) {
-	c.maxRetries = maxRetries
-	c.retryBackoff = backoff
+	c.SetRetryPolicy(&fixedBackoff{maxRetries: maxRetries, interval: backoff})
+}
+
+// SetRetryPolicy はリトライポリシーを設定します
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	if policy == nil {
+		policy = &ExponentialBackoff{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retryPolicy = policy
 }
 
 // Authenticate は認証フローを実行します
@@ -104,166 +230,367 @@ func (c *Client) SetRetry(maxRetries int, backoff time.Duration) {
 // 2. チャレンジに署名
 // 3. 署名を送信して認証
 func (c *Client) Authenticate() (*VerifyResponse, error) {
-	return c.authenticateWithRetry(c.maxRetries)
+	return c.AuthenticateContext(context.Background())
 }
 
-// authenticateWithRetry はリトライ付き認証を実行します
-func (c *Client) authenticateWithRetry(retriesLeft int) (*VerifyResponse, error) {
-	// チャレンジを取得
-	challengeResp, err := c.RequestChallenge()
-	if err != nil {
-		if retriesLeft > 0 && c.isRetryable(err) {
-			time.Sleep(c.retryBackoff)
-			return c.authenticateWithRetry(retriesLeft - 1)
+// AuthenticateContext はcontext付きで認証フローを実行します
+// ctxがキャンセルされるとリトライ待機中でも即座に中断します
+// 複数のgoroutineから同時に呼ばれた場合、実行中の認証を1つだけ行い結果を共有します
+func (c *Client) AuthenticateContext(ctx context.Context) (*VerifyResponse, error) {
+	for {
+		c.flightMu.Lock()
+		f := c.flight
+		leader := f == nil
+		if leader {
+			f = &authFlight{done: make(chan struct{})}
+			c.flight = f
+		}
+		c.flightMu.Unlock()
+
+		if leader {
+			f.resp, f.err = c.authenticateWithCache(ctx)
+
+			c.flightMu.Lock()
+			c.flight = nil
+			c.flightMu.Unlock()
+			close(f.done)
+
+			return cloneVerifyResponse(f.resp), f.err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-f.done:
+		}
+
+		// 先行した呼び出し側のcontextが原因で失敗した場合は、自身のcontextでやり直す
+		if f.err != nil && isContextError(f.err) && ctx.Err() == nil {
+			continue
 		}
-		return nil, fmt.Errorf("failed to request challenge: %w", err)
+		return cloneVerifyResponse(f.resp), f.err
 	}
+}
 
-	// チャレンジに署名
-	signature, err := c.signChallenge(challengeResp.Challenge)
-	if err != nil {
-		return nil, fmt.Errorf("failed to sign challenge: %w", err)
+// authenticateWithCache は認証を実行し、成功時はキャッシュを更新します
+// Workerに到達できない場合はキャッシュからStale=trueのレスポンスを返します
+func (c *Client) authenticateWithCache(ctx context.Context) (*VerifyResponse, error) {
+	resp, err := c.authenticateWithRetry(ctx)
+	if c.secretCache == nil {
+		return resp, err
 	}
 
-	// 署名を送信して認証
-	verifyResp, err := c.VerifySignature(challengeResp.Challenge, signature)
-	if err != nil {
-		if retriesLeft > 0 && c.isRetryable(err) {
-			time.Sleep(c.retryBackoff)
-			return c.authenticateWithRetry(retriesLeft - 1)
+	if err == nil {
+		resp.FetchedAt = time.Now()
+		if saveErr := c.secretCache.save(resp, resp.FetchedAt); saveErr != nil {
+			c.logger.Warn("failed to save secret cache", "error", saveErr)
 		}
-		return nil, fmt.Errorf("failed to verify signature: %w", err)
+		return resp, nil
 	}
 
-	return verifyResp, nil
+	if !isWorkerUnreachable(err) {
+		return nil, err
+	}
+
+	cached, loadErr := c.secretCache.load(time.Now())
+	if loadErr != nil {
+		c.logger.Warn("secret cache unavailable", "error", loadErr)
+		return nil, err
+	}
+
+	c.logger.Warn("worker unreachable, serving stale secrets from cache",
+		"client_id", c.clientID, "fetched_at", cached.FetchedAt, "error", err)
+	return cached, nil
 }
 
-// isRetryable はエラーがリトライ可能かどうかを判定します
-func (c *Client) isRetryable(err error) bool {
-	// ネットワークエラーはリトライ可能
-	if errors.Is(err, ErrNetworkError) {
-		return true
+// isContextError はエラーがcontextのキャンセル・期限切れによるものか判定します
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// cloneVerifyResponse は共有された認証結果を呼び出し側ごとに複製します
+func cloneVerifyResponse(resp *VerifyResponse) *VerifyResponse {
+	if resp == nil {
+		return nil
+	}
+
+	clone := *resp
+	if resp.SecretData != nil {
+		clone.SecretData = make(map[string]string, len(resp.SecretData))
+		for key, value := range resp.SecretData {
+			clone.SecretData[key] = value
+		}
 	}
+	if resp.RepoList != nil {
+		clone.RepoList = append([]string(nil), resp.RepoList...)
+	}
+	return &clone
+}
+
+// authenticateWithRetry はリトライポリシーに従って認証を繰り返します
+func (c *Client) authenticateWithRetry(ctx context.Context) (*VerifyResponse, error) {
+	c.mu.RLock()
+	policy := c.retryPolicy
+	c.mu.RUnlock()
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		resp, err := c.authenticateOnce(ctx)
+		c.metrics.attempts.Inc(c.clientID, attemptOutcome(err))
+		if err == nil {
+			return resp, nil
+		}
+		if isContextError(err) {
+			return nil, err
+		}
 
-	// HTTPエラーの場合、ステータスコードで判定
-	var httpErr *HTTPError
-	if errors.As(err, &httpErr) {
-		// 5xxエラーはリトライ可能
-		if httpErr.StatusCode >= 500 && httpErr.StatusCode < 600 {
-			return true
+		delay, retry := policy.NextDelay(attempt, time.Since(start), err)
+		if !retry {
+			return nil, err
 		}
-		// 429 Too Many Requestsもリトライ可能
-		if httpErr.StatusCode == 429 {
-			return true
+		c.metrics.retries.Inc(c.clientID)
+		c.hooks.retry(ctx, RetryEvent{ClientID: c.clientID, Attempt: attempt, Err: err, Delay: delay})
+		c.logger.Warn("retrying authentication",
+			"client_id", c.clientID, "attempt", attempt, "delay", delay, "error", err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
 		}
 	}
+}
 
-	return false
+// authenticateOnce はチャレンジ取得・署名・検証を1回実行します
+// Workerに到達できない場合は次のエンドポイントに切り替えます
+func (c *Client) authenticateOnce(ctx context.Context) (*VerifyResponse, error) {
+	return withFailover(c, ctx, func(e *endpoint) (*VerifyResponse, error) {
+		return c.authenticateOn(ctx, e)
+	})
 }
 
-// RequestChallenge はチャレンジを取得します
-func (c *Client) RequestChallenge() (*ChallengeResponse, error) {
-	url := fmt.Sprintf("%s/challenge", c.baseURL)
+// authenticateOn は指定したエンドポイントでチャレンジ取得・署名・検証を実行します
+// チャレンジは必ず発行したエンドポイントで検証します
+// 署名後にチャレンジが期限切れになっていた場合や、Workerが期限切れを返した場合は
+// 新しいチャレンジを取得し直します
+func (c *Client) authenticateOn(ctx context.Context, e *endpoint) (*VerifyResponse, error) {
+	var lastErr error
+	for i := 0; i < maxChallengeAttempts; i++ {
+		// チャレンジを取得
+		challengeResp, err := c.challengeOn(ctx, e)
+		if err != nil {
+			if isContextError(err) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("failed to request challenge: %w", err)
+		}
 
-	// リクエストボディを作成
-	reqBody := map[string]string{
-		"clientId": c.clientID,
+		// チャレンジに署名
+		signature, err := c.signOn(ctx, challengeResp.Challenge)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign challenge: %w", err)
+		}
+
+		// 署名に時間がかかり期限切れになった場合は取り直す
+		if challengeResp.Expired(time.Now(), c.clockSkew) {
+			c.logger.Info("challenge expired before verification, requesting a new one",
+				"client_id", c.clientID, "expires_at", time.Unix(challengeResp.ExpiresAt, 0))
+			lastErr = fmt.Errorf("%w: expired at %s before verification",
+				ErrChallengeExpired, time.Unix(challengeResp.ExpiresAt, 0).Format(time.RFC3339))
+			continue
+		}
+
+		// 署名を送信して認証
+		verifyResp, err := c.verifyOn(ctx, e, challengeResp.Challenge, signature)
+		if err != nil {
+			if isContextError(err) {
+				return nil, err
+			}
+			c.logger.Warn("verify failed", "client_id", c.clientID, "error", err)
+			lastErr = fmt.Errorf("failed to verify signature: %w", err)
+			if errors.Is(err, ErrChallengeExpired) {
+				continue
+			}
+			return nil, lastErr
+		}
+
+		c.logger.Info("verify succeeded",
+			"client_id", c.clientID, "endpoint", e.url, "secret_count", len(verifyResp.SecretData))
+		return verifyResp, nil
 	}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	return nil, lastErr
+}
+
+// doRequest はHTTPリクエストを送信し、ステータス200のレスポンスボディを返します
+// reqBodyがnilでない場合はJSONとして送信し、tokenが空でない場合はBearerトークンを付与します
+func (c *Client) doRequest(ctx context.Context, method, url string, reqBody any, token string) ([]byte, error) {
+	var bodyReader io.Reader
+	if reqBody != nil {
+		jsonData, err := json.Marshal(reqBody)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		bodyReader = bytes.NewReader(jsonData)
+	}
+
+	// httptraceを転送
+	if c.hooks.ClientTrace != nil {
+		ctx = httptrace.WithClientTrace(ctx, c.hooks.ClientTrace)
 	}
 
 	// HTTPリクエストを作成
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
 
 	// リクエストを送信
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		// キャンセル・期限切れはネットワークエラーとして扱わない（リトライ対象外）
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if errors.Is(err, ErrCertificatePinMismatch) {
+			return nil, fmt.Errorf("%w: %v", ErrCertificatePinMismatch, err)
+		}
 		return nil, fmt.Errorf("%w: %v", ErrNetworkError, err)
 	}
 	defer resp.Body.Close()
 
-	// レスポンスボディを読み込み
-	body, err := io.ReadAll(resp.Body)
+	// ピン留めされた証明書以外からのレスポンスは読まずに破棄
+	if c.pins != nil {
+		if err := c.pins.verify(resp.TLS); err != nil {
+			c.logger.Error("worker certificate does not match pinned keys", "url", url)
+			return nil, err
+		}
+	}
+
+	// レスポンスボディを最大サイズまで読み込み
+	body, err := c.readResponseBody(req.URL.Path, resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
 	// ステータスコードをチェック
 	if resp.StatusCode != http.StatusOK {
-		return nil, c.handleHTTPError(resp.StatusCode, body)
+		return nil, c.handleHTTPError(resp.StatusCode, resp.Header, body)
+	}
+
+	if err := c.checkContentType(req.URL.Path, resp.Header.Get("Content-Type")); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// RequestChallenge はチャレンジを取得します
+func (c *Client) RequestChallenge() (*ChallengeResponse, error) {
+	return c.RequestChallengeContext(context.Background())
+}
+
+// RequestChallengeContext はcontext付きでチャレンジを取得します
+// 取得したチャレンジはVerifySignatureで同じエンドポイントに送信されます
+func (c *Client) RequestChallengeContext(ctx context.Context) (*ChallengeResponse, error) {
+	var issuer *endpoint
+	challengeResp, err := withFailover(c, ctx, func(e *endpoint) (*ChallengeResponse, error) {
+		issuer = e
+		return c.challengeOn(ctx, e)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.endpoints.rememberChallenge(challengeResp.Challenge, issuer,
+		time.Unix(challengeResp.ExpiresAt, 0).Add(c.clockSkew))
+	return challengeResp, nil
+}
+
+// fetchChallenge は指定したエンドポイントからチャレンジを取得します
+func (c *Client) fetchChallenge(ctx context.Context, e *endpoint) (*ChallengeResponse, error) {
+	url := endpointURL(e, "/challenge")
+
+	// リクエストボディを作成
+	reqBody := map[string]string{
+		"clientId": c.clientID,
+	}
+
+	start := time.Now()
+	body, err := c.doRequest(ctx, http.MethodPost, url, reqBody, "")
+	c.metrics.observeRequest(c.clientID, "challenge", start)
+	if err != nil {
+		return nil, err
 	}
 
 	// レスポンスをパース
 	var challengeResp ChallengeResponse
-	if err := json.Unmarshal(body, &challengeResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if err := c.decodeResponse("/challenge", body, &challengeResp); err != nil {
+		return nil, err
 	}
 
+	c.logger.Debug("challenge requested",
+		"client_id", c.clientID, "endpoint", e.url, "expires_at", time.Unix(challengeResp.ExpiresAt, 0))
+
 	return &challengeResp, nil
 }
 
 // VerifySignature は署名を検証してSecret変数を取得します
 func (c *Client) VerifySignature(challenge, signature string) (*VerifyResponse, error) {
-	url := fmt.Sprintf("%s/verify", c.baseURL)
+	return c.VerifySignatureContext(context.Background(), challenge, signature)
+}
+
+// VerifySignatureContext はcontext付きで署名を検証してSecret変数を取得します
+// チャレンジを発行したエンドポイントに送信します（フェイルオーバーはしません）
+func (c *Client) VerifySignatureContext(ctx context.Context, challenge, signature string) (*VerifyResponse, error) {
+	e := c.endpoints.challengeEndpoint(challenge)
+	verifyResp, err := c.verifyOn(ctx, e, challenge, signature)
+	c.record(e, err)
+	return verifyResp, err
+}
+
+// postVerify は指定したエンドポイントで署名を検証してSecret変数を取得します
+func (c *Client) postVerify(ctx context.Context, e *endpoint, challenge, signature string) (*VerifyResponse, error) {
+	url := endpointURL(e, "/verify")
+
+	c.mu.RLock()
+	tunnelUrl := c.tunnelUrl
+	c.mu.RUnlock()
 
 	// リクエストボディを作成
 	reqBody := VerifyRequest{
 		ClientID:        c.clientID,
 		Challenge:       challenge,
 		Signature:       signature,
+		Algorithm:       string(c.algorithm),
 		RepoUrl:         c.repoUrl,
 		GrpcEndpoint:    c.grpcEndpoint,
 		IncludeRepoList: c.includeRepoList,
-		TunnelUrl:       c.tunnelUrl,
+		TunnelUrl:       tunnelUrl,
 	}
 
-	jsonData, err := json.Marshal(reqBody)
+	start := time.Now()
+	body, err := c.doRequest(ctx, http.MethodPost, url, reqBody, "")
+	c.metrics.observeRequest(c.clientID, "verify", start)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// HTTPリクエストを作成
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	// リクエストを送信
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrNetworkError, err)
-	}
-	defer resp.Body.Close()
-
-	// レスポンスボディを読み込み
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	// ステータスコードをチェック
-	if resp.StatusCode != http.StatusOK {
-		return nil, c.handleHTTPError(resp.StatusCode, body)
+		return nil, classifyVerifyError(err)
 	}
 
 	// レスポンスをパース
 	var verifyResp VerifyResponse
-	if err := json.Unmarshal(body, &verifyResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if err := c.decodeResponse("/verify", body, &verifyResp); err != nil {
+		return nil, err
 	}
 
 	// 認証失敗チェック
 	if !verifyResp.Success {
+		if cause := verifyFailureCause(verifyResp.Error); cause != nil {
+			return nil, fmt.Errorf("%w: %w: %s", ErrUnauthorized, cause, verifyResp.Error)
+		}
 		return nil, fmt.Errorf("%w: %s", ErrUnauthorized, verifyResp.Error)
 	}
 
@@ -280,7 +607,12 @@ func (c *Client) VerifySignature(challenge, signature string) (*VerifyResponse,
 
 	// アクセストークンを保存
 	if verifyResp.AccessToken != "" {
-		c.accessToken = verifyResp.AccessToken
+		c.storeAccessToken(verifyResp.AccessToken, unixTime(verifyResp.AccessTokenExpiresAt))
+		c.logger.Info("access token stored", "client_id", c.clientID)
+		c.hooks.tokenStored(ctx, TokenEvent{
+			ClientID:  c.clientID,
+			ExpiresAt: unixTime(verifyResp.AccessTokenExpiresAt),
+		})
 	}
 
 	return &verifyResp, nil
@@ -288,38 +620,28 @@ func (c *Client) VerifySignature(challenge, signature string) (*VerifyResponse,
 
 // Health はヘルスチェックを実行します
 func (c *Client) Health() (*HealthResponse, error) {
-	url := fmt.Sprintf("%s/health", c.baseURL)
-
-	resp, err := c.httpClient.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrNetworkError, err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, c.handleHTTPError(resp.StatusCode, body)
-	}
-
-	var healthResp HealthResponse
-	if err := json.Unmarshal(body, &healthResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
+	return c.HealthContext(context.Background())
+}
 
-	return &healthResp, nil
+// HealthContext はcontext付きでヘルスチェックを実行します
+// 複数のエンドポイントが設定されている場合は最初に応答したエンドポイントの結果を返します
+func (c *Client) HealthContext(ctx context.Context) (*HealthResponse, error) {
+	return withFailover(c, ctx, func(e *endpoint) (*HealthResponse, error) {
+		return c.healthOn(ctx, e)
+	})
 }
 
 // handleHTTPError はHTTPエラーを処理します
-func (c *Client) handleHTTPError(statusCode int, body []byte) error {
+func (c *Client) handleHTTPError(statusCode int, header http.Header, body []byte) error {
+	retryAfter := parseRetryAfter(header.Get("Retry-After"))
+
 	// エラーレスポンスをパース
 	var errResp ErrorResponse
 	if err := json.Unmarshal(body, &errResp); err != nil {
 		// パース失敗時はステータスコードのみでエラーを返す
-		return NewHTTPError(statusCode, string(body), nil)
+		httpErr := NewHTTPError(statusCode, string(body), nil)
+		httpErr.RetryAfter = retryAfter
+		return httpErr
 	}
 
 	// ステータスコードに応じたエラーを返す
@@ -329,6 +651,8 @@ func (c *Client) handleHTTPError(statusCode int, body []byte) error {
 		baseErr = ErrBadRequest
 	case http.StatusUnauthorized:
 		baseErr = ErrUnauthorized
+	case http.StatusForbidden:
+		baseErr = ErrForbidden
 	case http.StatusNotFound:
 		baseErr = ErrNotFound
 	case http.StatusInternalServerError:
@@ -337,53 +661,39 @@ func (c *Client) handleHTTPError(statusCode int, body []byte) error {
 		baseErr = fmt.Errorf("HTTP error %d", statusCode)
 	}
 
-	return NewHTTPError(statusCode, errResp.Error, baseErr)
+	httpErr := NewHTTPError(statusCode, errResp.Error, baseErr)
+	httpErr.RetryAfter = retryAfter
+	return httpErr
 }
 
 // RegisterTunnel はトンネルURLを登録または更新します
 func (c *Client) RegisterTunnel(tunnelUrl string) (*TunnelRegisterResponse, error) {
-	if c.accessToken == "" {
+	return c.RegisterTunnelContext(context.Background(), tunnelUrl)
+}
+
+// RegisterTunnelContext はcontext付きでトンネルURLを登録または更新します
+func (c *Client) RegisterTunnelContext(ctx context.Context, tunnelUrl string) (*TunnelRegisterResponse, error) {
+	accessToken := c.GetAccessToken()
+	if accessToken == "" {
 		return nil, fmt.Errorf("%w: accessToken is required, please authenticate first", ErrInvalidConfig)
 	}
 
-	url := fmt.Sprintf("%s/tunnel/register", c.baseURL)
-
 	reqBody := TunnelRegisterRequest{
 		ClientID:  c.clientID,
 		TunnelUrl: tunnelUrl,
-		Token:     c.accessToken,
+		Token:     accessToken,
 	}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrNetworkError, err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := withFailover(c, ctx, func(e *endpoint) ([]byte, error) {
+		return c.doRequest(ctx, http.MethodPost, endpointURL(e, "/tunnel/register"), reqBody, "")
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, c.handleHTTPError(resp.StatusCode, body)
+		return nil, err
 	}
 
 	var tunnelResp TunnelRegisterResponse
-	if err := json.Unmarshal(body, &tunnelResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if err := c.decodeResponse("/tunnel/register", body, &tunnelResp); err != nil {
+		return nil, err
 	}
 
 	if !tunnelResp.Success {
@@ -391,46 +701,26 @@ func (c *Client) RegisterTunnel(tunnelUrl string) (*TunnelRegisterResponse, erro
 	}
 
 	// トンネルURLを更新
+	c.mu.Lock()
 	c.tunnelUrl = tunnelUrl
+	c.mu.Unlock()
+
+	c.logger.Info("tunnel registered", "client_id", c.clientID, "tunnel_url", tunnelUrl)
 
 	return &tunnelResp, nil
 }
 
 // GetTunnel はトンネル情報を取得します
 func (c *Client) GetTunnel() (*TunnelGetResponse, error) {
-	if c.accessToken == "" {
-		return nil, fmt.Errorf("%w: accessToken is required, please authenticate first", ErrInvalidConfig)
-	}
-
-	url := fmt.Sprintf("%s/tunnel/%s", c.baseURL, c.clientID)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrNetworkError, err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, c.handleHTTPError(resp.StatusCode, body)
-	}
+	return c.GetTunnelContext(context.Background())
+}
 
+// GetTunnelContext はcontext付きでトンネル情報を取得します
+func (c *Client) GetTunnelContext(ctx context.Context) (*TunnelGetResponse, error) {
 	var tunnelResp TunnelGetResponse
-	if err := json.Unmarshal(body, &tunnelResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if err := c.tunnelRequest(ctx, http.MethodGet, "/tunnel/"+url.PathEscape(c.clientID), &tunnelResp); err != nil {
+		return nil, classifyTunnelError(err)
 	}
-
 	if !tunnelResp.Success {
 		return nil, fmt.Errorf("%w: %s", ErrUnauthorized, tunnelResp.Error)
 	}
@@ -440,10 +730,48 @@ func (c *Client) GetTunnel() (*TunnelGetResponse, error) {
 
 // GetAccessToken はアクセストークンを返します
 func (c *Client) GetAccessToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.accessToken
 }
 
-// SetAccessToken はアクセストークンを設定します
+// GetAccessTokenExpiresAt はアクセストークンの有効期限を返します
+// Workerが有効期限を返さなかった場合やSetAccessTokenで設定した場合はゼロ値です
+func (c *Client) GetAccessTokenExpiresAt() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tokenExpires
+}
+
+// SetAccessToken はアクセストークンを設定します（有効期限は不明として扱います）
 func (c *Client) SetAccessToken(token string) {
+	c.storeAccessToken(token, time.Time{})
+}
+
+// RenewAccessToken は再認証して新しいアクセストークンを取得します
+// オフラインキャッシュから応答した場合は新しいトークンが含まれないため、ErrStaleSecretsを返します
+// 取得したトークンと有効期限はGetAccessTokenとGetAccessTokenExpiresAtで参照できます
+func (c *Client) RenewAccessToken(ctx context.Context) error {
+	resp, err := c.AuthenticateContext(ctx)
+	if err != nil {
+		return err
+	}
+	if resp.Stale {
+		return ErrStaleSecrets
+	}
+	return nil
+}
+
+// storeAccessToken はアクセストークンと有効期限を保存します
+func (c *Client) storeAccessToken(token string, expiresAt time.Time) {
+	var storedAt time.Time
+	if token != "" {
+		storedAt = time.Now()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.accessToken = token
+	c.tokenExpires = expiresAt
+	c.metrics.recordTokenStored(c.clientID, storedAt)
 }
@@ -1,11 +1,23 @@
 package authclient
 
 import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -142,6 +154,25 @@ func TestNewClient(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "signer instead of privateKey",
+			config: ClientConfig{
+				BaseURL:  "https://test.example.com",
+				ClientID: "test-client",
+				Signer:   privateKey,
+			},
+			wantErr: false,
+		},
+		{
+			name: "both privateKey and signer",
+			config: ClientConfig{
+				BaseURL:    "https://test.example.com",
+				ClientID:   "test-client",
+				PrivateKey: privateKey,
+				Signer:     privateKey,
+			},
+			wantErr: true,
+		},
 		{
 			name: "missing privateKey",
 			config: ClientConfig{
@@ -280,6 +311,170 @@ func TestAuthenticate(t *testing.T) {
 	}
 }
 
+// countingSigner は秘密鍵を公開しないcrypto.Signer
+type countingSigner struct {
+	key   *rsa.PrivateKey
+	calls atomic.Int32
+}
+
+func (s *countingSigner) Public() crypto.PublicKey {
+	return &s.key.PublicKey
+}
+
+func (s *countingSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.calls.Add(1)
+	return s.key.Sign(rand, digest, opts)
+}
+
+func TestAuthenticate_WithSigner(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	server := setupTestServer(t, privateKey)
+	defer server.Close()
+
+	signer := &countingSigner{key: privateKey}
+	client, err := NewClient(ClientConfig{
+		BaseURL:  server.URL,
+		ClientID: "test-client",
+		Signer:   signer,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if got := signer.calls.Load(); got != 1 {
+		t.Errorf("Sign() called %d times, want 1", got)
+	}
+	if pub, ok := client.PublicKey().(*rsa.PublicKey); !ok || !pub.Equal(&privateKey.PublicKey) {
+		t.Errorf("PublicKey() = %v, want signer public key", client.PublicKey())
+	}
+}
+
+func TestAuthenticate_Algorithms(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ecdsa key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ed25519 key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		signer    crypto.Signer
+		algorithm string
+		want      string
+	}{
+		{name: "ecdsa default", signer: ecKey, want: AlgorithmES256},
+		{name: "ed25519 default", signer: edKey, want: AlgorithmEdDSA},
+		{name: "rsa default", signer: rsaKey, want: AlgorithmRS256},
+		{name: "rsa pss", signer: rsaKey, algorithm: AlgorithmPS256, want: AlgorithmPS256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/challenge", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(ChallengeResponse{
+					Challenge: "algorithm-challenge",
+					ExpiresAt: time.Now().Add(time.Minute).Unix(),
+				})
+			})
+			mux.HandleFunc("/verify", func(w http.ResponseWriter, r *http.Request) {
+				var req VerifyRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				w.Header().Set("Content-Type", "application/json")
+				if req.Algorithm != tt.want {
+					w.WriteHeader(http.StatusBadRequest)
+					json.NewEncoder(w).Encode(ErrorResponse{Error: "unexpected algorithm " + req.Algorithm})
+					return
+				}
+				alg := internalcrypto.Algorithm(req.Algorithm)
+				if err := internalcrypto.VerifySignatureWithAlgorithm(tt.signer.Public(), alg, req.Challenge, req.Signature); err != nil {
+					w.WriteHeader(http.StatusUnauthorized)
+					json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid signature"})
+					return
+				}
+				json.NewEncoder(w).Encode(VerifyResponse{Success: true, AccessToken: "token"})
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			client, err := NewClient(ClientConfig{
+				BaseURL:   server.URL,
+				ClientID:  "test-client",
+				Signer:    tt.signer,
+				Algorithm: tt.algorithm,
+			})
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+
+			if _, err := client.Authenticate(); err != nil {
+				t.Errorf("Authenticate() error = %v", err)
+			}
+		})
+	}
+
+	// 鍵の型と合わないアルゴリズムは設定エラー
+	_, err = NewClient(ClientConfig{
+		BaseURL:   "https://test.example.com",
+		ClientID:  "test-client",
+		Signer:    ecKey,
+		Algorithm: AlgorithmPS256,
+	})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("NewClient() error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestAuthenticate_LogsWithoutSecrets(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	authServer, _ := setupTokenServer(t, nil)
+	defer authServer.Close()
+
+	var buf bytes.Buffer
+	client, err := NewClient(ClientConfig{
+		BaseURL:    authServer.URL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+		Logger:     slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	logged := buf.String()
+	for _, event := range []string{"challenge requested", "verify succeeded", "access token stored"} {
+		if !strings.Contains(logged, event) {
+			t.Errorf("log does not contain %q: %s", event, logged)
+		}
+	}
+	if strings.Contains(logged, client.GetAccessToken()) {
+		t.Errorf("log contains access token: %s", logged)
+	}
+}
+
 func TestHealth(t *testing.T) {
 	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
@@ -308,3 +503,167 @@ func TestHealth(t *testing.T) {
 		t.Errorf("Health() status = %s, want ok", resp.Status)
 	}
 }
+
+func TestAuthenticateContext_CancelDuringBackoff(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	// 常に503を返すサーバー
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "unavailable"})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:    server.URL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	client.SetRetry(5, time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = client.AuthenticateContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("AuthenticateContext() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("AuthenticateContext() did not honour cancellation, took %v", elapsed)
+	}
+}
+
+func TestHealthContext_Canceled(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	server := setupTestServer(t, privateKey)
+	defer server.Close()
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:    server.URL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.HealthContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("HealthContext() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestAuthenticate_SingleFlight(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var challenges atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/challenge", func(w http.ResponseWriter, r *http.Request) {
+		challenges.Add(1)
+		// 同時呼び出しが確実に重なるよう遅延させる
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ChallengeResponse{
+			Challenge: "test-challenge",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		})
+	})
+	mux.HandleFunc("/verify", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(VerifyResponse{
+			Success:     true,
+			AccessToken: "shared-token",
+			SecretData:  map[string]string{"KEY": "value"},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:    server.URL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	const callers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Authenticate()
+			if err != nil {
+				errs <- err
+				return
+			}
+			// 結果は呼び出し側ごとに独立している
+			resp.SecretData["KEY"] = "mutated"
+			_ = client.GetAccessToken()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Authenticate() error = %v", err)
+	}
+	if got := challenges.Load(); got != 1 {
+		t.Errorf("/challenge called %d times, want 1", got)
+	}
+	if got := client.GetAccessToken(); got != "shared-token" {
+		t.Errorf("GetAccessToken() = %q, want shared-token", got)
+	}
+}
+
+func TestGetTunnel_EscapesClientID(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "data": map[string]any{"clientId": "team/app?x#y"}})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:    server.URL,
+		ClientID:   "team/app?x#y",
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	client.SetAccessToken("test-token")
+
+	if _, err := client.GetTunnel(); err != nil {
+		t.Fatalf("GetTunnel() error = %v", err)
+	}
+	if want := "/tunnel/team%2Fapp%3Fx%23y"; gotPath != want {
+		t.Errorf("request path = %q, want %q", gotPath, want)
+	}
+}
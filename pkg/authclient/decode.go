@@ -0,0 +1,350 @@
+package authclient
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrMissingSecret は必須のSecret変数が存在しない場合のエラー
+var ErrMissingSecret = errors.New("missing required secret")
+
+// FieldError は1つのフィールドのデコードエラー
+type FieldError struct {
+	// Key はSecret変数のキー
+	Key string
+
+	// Field は構造体のフィールド名（ネストしている場合はドット区切り）
+	Field string
+
+	// Err は原因となったエラー
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s (field %s): %v", e.Key, e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError は全てのフィールドのデコードエラーをまとめたエラー
+type DecodeError struct {
+	Errors []*FieldError
+}
+
+func (e *DecodeError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		msgs[i] = fieldErr.Error()
+	}
+	return fmt.Sprintf("failed to decode secrets: %s", strings.Join(msgs, "; "))
+}
+
+func (e *DecodeError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		errs[i] = fieldErr
+	}
+	return errs
+}
+
+// Decode はSecretDataを構造体にデコードします
+// 詳細はDecodeSecretsを参照してください
+func (resp *VerifyResponse) Decode(v any) error {
+	return DecodeSecrets(resp.SecretData, v)
+}
+
+// DecodeSecrets はSecret変数のマップをsecretタグに従って構造体にデコードします
+//
+//	type Config struct {
+//	    DatabaseURL *url.URL      `secret:"DB_URL,required"`
+//	    Timeout     time.Duration `secret:"TIMEOUT,default=5s"`
+//	    Hosts       []string      `secret:"HOSTS"`      // カンマ区切り
+//	    Redis       RedisConfig   `secret:"REDIS"`      // REDIS_ をキーの接頭辞とするネスト
+//	}
+//
+// string、bool、整数、浮動小数点数、time.Duration、url.URL、encoding.TextUnmarshaler、
+// それらのスライスとポインタに対応しています
+// ネストした構造体のポインタは、その接頭辞を持つキーが1つ以上ある場合か、
+// requiredまたはdefaultのフィールドを持つ場合に確保してデコードします
+// （自己参照する構造体はキーがなくなった時点で止まります）
+// 不足・不正な全てのキーを*DecodeErrorにまとめて返します
+func DecodeSecrets(secrets map[string]string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("decode target must be a non-nil pointer to a struct, got %T", v)
+	}
+
+	d := &secretDecoder{secrets: secrets, decoding: make(map[decodingStruct]bool)}
+	d.decodeStruct(rv.Elem(), "", "")
+
+	if len(d.errs) > 0 {
+		return &DecodeError{Errors: d.errs}
+	}
+	return nil
+}
+
+// secretDecoder はデコード中の状態を保持します
+type secretDecoder struct {
+	secrets  map[string]string
+	errs     []*FieldError
+	decoding map[decodingStruct]bool
+}
+
+// decodingStruct はデコード中の構造体の型とキーの接頭辞
+// 同じ組み合わせが再び現れた場合は、接頭辞が増えない自己参照として打ち切ります
+type decodingStruct struct {
+	typ    reflect.Type
+	prefix string
+}
+
+var (
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	durationType        = reflect.TypeFor[time.Duration]()
+	urlType             = reflect.TypeFor[url.URL]()
+)
+
+// secretTag はsecretタグの内容
+type secretTag struct {
+	key        string
+	required   bool
+	defaultVal string
+	hasDefault bool
+}
+
+// parseSecretTag はsecretタグをパースします
+// default=以降はカンマを含めて全て既定値として扱います
+func parseSecretTag(tag string) secretTag {
+	key, opts, _ := strings.Cut(tag, ",")
+	parsed := secretTag{key: key}
+
+	for opts != "" {
+		if value, ok := strings.CutPrefix(opts, "default="); ok {
+			parsed.defaultVal = value
+			parsed.hasDefault = true
+			break
+		}
+
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == "required" {
+			parsed.required = true
+		}
+	}
+
+	return parsed
+}
+
+// decodeStruct は構造体の各フィールドをデコードします
+func (d *secretDecoder) decodeStruct(rv reflect.Value, keyPrefix, fieldPrefix string) {
+	rt := rv.Type()
+	current := decodingStruct{typ: rt, prefix: keyPrefix}
+	d.decoding[current] = true
+	defer delete(d.decoding, current)
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag, hasTag := field.Tag.Lookup("secret")
+		if tag == "-" {
+			continue
+		}
+		parsed := parseSecretTag(tag)
+		fieldName := fieldPrefix + field.Name
+		fv := rv.Field(i)
+
+		// ネストした構造体（タグのキーは接頭辞になる）
+		if isNestedStruct(field.Type) {
+			prefix := keyPrefix
+			if parsed.key != "" {
+				prefix = keyPrefix + parsed.key + "_"
+			}
+			if field.Type.Kind() == reflect.Pointer {
+				if !d.shouldDecodePointer(field.Type.Elem(), prefix) {
+					continue
+				}
+				if fv.IsNil() {
+					fv.Set(reflect.New(field.Type.Elem()))
+				}
+				fv = fv.Elem()
+			}
+			d.decodeStruct(fv, prefix, fieldName+".")
+			continue
+		}
+
+		if !hasTag || parsed.key == "" {
+			continue
+		}
+
+		key := keyPrefix + parsed.key
+		value, ok := d.secrets[key]
+		if !ok || value == "" {
+			switch {
+			case parsed.hasDefault:
+				value = parsed.defaultVal
+			case parsed.required:
+				d.errs = append(d.errs, &FieldError{Key: key, Field: fieldName, Err: ErrMissingSecret})
+				continue
+			default:
+				continue
+			}
+		}
+
+		if err := setSecretValue(fv, value); err != nil {
+			d.errs = append(d.errs, &FieldError{Key: key, Field: fieldName, Err: err})
+		}
+	}
+}
+
+// shouldDecodePointer はネストした構造体のポインタを確保してデコードするかどうかを判定します
+// キーがなくてもrequired・defaultのフィールドがあれば確保し、不足を報告・既定値を設定します
+// ただしデコード中の型が再び現れた場合（自己参照）は、キーがある場合のみ確保します
+func (d *secretDecoder) shouldDecodePointer(t reflect.Type, prefix string) bool {
+	if d.decoding[decodingStruct{typ: t, prefix: prefix}] {
+		return false
+	}
+	if d.hasKeyWithPrefix(prefix) {
+		return true
+	}
+	for decoding := range d.decoding {
+		if decoding.typ == t {
+			return false
+		}
+	}
+	return hasRequiredOrDefault(t)
+}
+
+// hasRequiredOrDefault は構造体がrequiredまたはdefaultのフィールドを持つかどうかを判定します
+// 値として埋め込まれたネストした構造体のフィールドも対象です
+func hasRequiredOrDefault(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("secret")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		if isNestedStruct(field.Type) {
+			if field.Type.Kind() != reflect.Pointer && hasRequiredOrDefault(field.Type) {
+				return true
+			}
+			continue
+		}
+		if parsed := parseSecretTag(tag); parsed.key != "" && (parsed.required || parsed.hasDefault) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasKeyWithPrefix は接頭辞を持つSecret変数が存在するかどうかを判定します
+func (d *secretDecoder) hasKeyWithPrefix(prefix string) bool {
+	for key := range d.secrets {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNestedStruct は型が再帰的にデコードする構造体かどうかを判定します
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == urlType {
+		return false
+	}
+	return !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// setSecretValue は文字列の値をフィールドの型に変換して設定します
+func setSecretValue(fv reflect.Value, value string) error {
+	// ポインタは必要に応じて確保
+	if fv.Kind() == reflect.Pointer {
+		elem := reflect.New(fv.Type().Elem())
+		if err := setSecretValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		fv.Set(elem)
+		return nil
+	}
+
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+
+	switch fv.Type() {
+	case durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	case urlType:
+		u, err := url.Parse(value)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(*u))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 0, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 0, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		parts := strings.Split(value, ",")
+		slice := reflect.MakeSlice(fv.Type(), 0, len(parts))
+		for i, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			elem := reflect.New(fv.Type().Elem()).Elem()
+			if err := setSecretValue(elem, part); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		fv.Set(slice)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package authclient
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type redisSecrets struct {
+	Addr string `secret:"ADDR,required"`
+	DB   int    `secret:"DB,default=0"`
+}
+
+type appSecrets struct {
+	DatabaseURL *url.URL      `secret:"DB_URL,required"`
+	Password    string        `secret:"DB_PASSWORD"`
+	Timeout     time.Duration `secret:"TIMEOUT,default=5s"`
+	Debug       bool          `secret:"DEBUG"`
+	MaxConns    uint16        `secret:"MAX_CONNS"`
+	Ratio       float64       `secret:"RATIO"`
+	Hosts       []string      `secret:"HOSTS,default=a.example.com,b.example.com"`
+	Ports       []int         `secret:"PORTS"`
+	BindIP      net.IP        `secret:"BIND_IP"`
+	Endpoint    url.URL       `secret:"ENDPOINT"`
+	Redis       redisSecrets  `secret:"REDIS"`
+	Ignored     string        `secret:"-"`
+	Untagged    string
+}
+
+func TestDecodeSecrets(t *testing.T) {
+	resp := &VerifyResponse{
+		SecretData: map[string]string{
+			"DB_URL":      "postgres://db.example.com:5432/app",
+			"DB_PASSWORD": "hunter2",
+			"DEBUG":       "true",
+			"MAX_CONNS":   "64",
+			"RATIO":       "0.75",
+			"PORTS":       "80, 443",
+			"BIND_IP":     "10.0.0.1",
+			"ENDPOINT":    "https://api.example.com",
+			"REDIS_ADDR":  "redis:6379",
+			"Untagged":    "should-not-be-set",
+		},
+	}
+
+	var cfg appSecrets
+	if err := resp.Decode(&cfg); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if cfg.DatabaseURL == nil || cfg.DatabaseURL.Host != "db.example.com:5432" {
+		t.Errorf("DatabaseURL = %v", cfg.DatabaseURL)
+	}
+	if cfg.Password != "hunter2" {
+		t.Errorf("Password = %q", cfg.Password)
+	}
+	if cfg.Timeout != 5*time.Second {
+		t.Errorf("Timeout = %v, want default 5s", cfg.Timeout)
+	}
+	if !cfg.Debug || cfg.MaxConns != 64 || cfg.Ratio != 0.75 {
+		t.Errorf("Debug/MaxConns/Ratio = %v/%d/%v", cfg.Debug, cfg.MaxConns, cfg.Ratio)
+	}
+	if strings.Join(cfg.Hosts, " ") != "a.example.com b.example.com" {
+		t.Errorf("Hosts = %v, want default list", cfg.Hosts)
+	}
+	if len(cfg.Ports) != 2 || cfg.Ports[0] != 80 || cfg.Ports[1] != 443 {
+		t.Errorf("Ports = %v", cfg.Ports)
+	}
+	if !cfg.BindIP.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("BindIP = %v (TextUnmarshaler)", cfg.BindIP)
+	}
+	if cfg.Endpoint.Host != "api.example.com" {
+		t.Errorf("Endpoint = %v", cfg.Endpoint)
+	}
+	if cfg.Redis.Addr != "redis:6379" || cfg.Redis.DB != 0 {
+		t.Errorf("Redis = %+v", cfg.Redis)
+	}
+	if cfg.Untagged != "" || cfg.Ignored != "" {
+		t.Errorf("untagged fields were set: %q %q", cfg.Untagged, cfg.Ignored)
+	}
+}
+
+func TestDecodeSecrets_AggregatedErrors(t *testing.T) {
+	secrets := map[string]string{
+		"TIMEOUT":   "five seconds",
+		"MAX_CONNS": "-1",
+		"PORTS":     "80,http",
+	}
+
+	var cfg appSecrets
+	err := DecodeSecrets(secrets, &cfg)
+
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("DecodeSecrets() error = %v, want *DecodeError", err)
+	}
+
+	got := map[string]bool{}
+	for _, fieldErr := range decodeErr.Errors {
+		got[fieldErr.Key] = true
+	}
+	for _, key := range []string{"DB_URL", "TIMEOUT", "MAX_CONNS", "PORTS", "REDIS_ADDR"} {
+		if !got[key] {
+			t.Errorf("DecodeError does not report %s: %v", key, err)
+		}
+	}
+	if len(decodeErr.Errors) != 5 {
+		t.Errorf("DecodeError has %d errors, want 5: %v", len(decodeErr.Errors), err)
+	}
+	if !errors.Is(err, ErrMissingSecret) {
+		t.Errorf("DecodeSecrets() error = %v, want to match ErrMissingSecret", err)
+	}
+}
+
+// secretNode は自己参照するネストした構造体
+type secretNode struct {
+	Name   string      `secret:"NAME"`
+	Next   *secretNode `secret:"NEXT"`
+	Parent *secretNode
+}
+
+func TestDecodeSecrets_SelfReferentialPointer(t *testing.T) {
+	secrets := map[string]string{
+		"NAME":           "root",
+		"NEXT_NAME":      "child",
+		"NEXT_NEXT_NAME": "grandchild",
+	}
+
+	var node secretNode
+	if err := DecodeSecrets(secrets, &node); err != nil {
+		t.Fatalf("DecodeSecrets() error = %v", err)
+	}
+
+	if node.Name != "root" || node.Next == nil || node.Next.Name != "child" ||
+		node.Next.Next == nil || node.Next.Next.Name != "grandchild" {
+		t.Fatalf("DecodeSecrets() = %+v", node)
+	}
+	// キーがなくなった階層と、接頭辞が変わらない自己参照は確保しない
+	if node.Next.Next.Next != nil {
+		t.Errorf("Next.Next.Next = %+v, want nil", node.Next.Next.Next)
+	}
+	if node.Parent != nil {
+		t.Errorf("Parent = %+v, want nil", node.Parent)
+	}
+}
+
+// secretTLS は必須のフィールドと既定値を持つネストした構造体
+type secretTLS struct {
+	Cert       string `secret:"CERT,required"`
+	MinVersion string `secret:"MIN_VERSION,default=1.2"`
+}
+
+// secretPool は既定値だけを持つネストした構造体
+type secretPool struct {
+	Size int `secret:"SIZE,default=4"`
+}
+
+// secretRequiredNode は必須のフィールドを持つ自己参照する構造体
+type secretRequiredNode struct {
+	Name string              `secret:"NAME,required"`
+	Next *secretRequiredNode `secret:"NEXT"`
+}
+
+func TestDecodeSecrets_PointerWithoutKeys(t *testing.T) {
+	var cfg struct {
+		TLS      *secretTLS  `secret:"TLS"`
+		Pool     *secretPool `secret:"POOL"`
+		Optional *secretNode `secret:"OPTIONAL"`
+	}
+
+	// キーがなくても必須のフィールドは不足として報告する
+	err := DecodeSecrets(map[string]string{}, &cfg)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || len(decodeErr.Errors) != 1 {
+		t.Fatalf("DecodeSecrets() error = %v, want 1 field error", err)
+	}
+	if got := decodeErr.Errors[0]; got.Key != "TLS_CERT" || got.Field != "TLS.Cert" || !errors.Is(got, ErrMissingSecret) {
+		t.Errorf("FieldError = %+v", got)
+	}
+
+	// 既定値は適用し、required・defaultのない構造体は確保しない
+	if cfg.Pool == nil || cfg.Pool.Size != 4 {
+		t.Errorf("Pool = %+v, want Size 4", cfg.Pool)
+	}
+	if cfg.TLS == nil || cfg.TLS.MinVersion != "1.2" {
+		t.Errorf("TLS = %+v, want MinVersion 1.2", cfg.TLS)
+	}
+	if cfg.Optional != nil {
+		t.Errorf("Optional = %+v, want nil", cfg.Optional)
+	}
+
+	// 自己参照する構造体はキーがなくなった階層で止まる
+	var node secretRequiredNode
+	if err := DecodeSecrets(map[string]string{"NAME": "root"}, &node); err != nil {
+		t.Fatalf("DecodeSecrets() error = %v", err)
+	}
+	if node.Next != nil {
+		t.Errorf("Next = %+v, want nil", node.Next)
+	}
+}
+
+func TestDecodeSecrets_InvalidTarget(t *testing.T) {
+	var cfg appSecrets
+	if err := DecodeSecrets(nil, cfg); err == nil {
+		t.Error("DecodeSecrets(non-pointer) error = nil, want error")
+	}
+	var s string
+	if err := DecodeSecrets(nil, &s); err == nil {
+		t.Error("DecodeSecrets(*string) error = nil, want error")
+	}
+}
+
+func TestParseSecretTag(t *testing.T) {
+	tag := parseSecretTag("HOSTS,required,default=a,b")
+	if tag.key != "HOSTS" || !tag.required || !tag.hasDefault || tag.defaultVal != "a,b" {
+		t.Errorf("parseSecretTag() = %+v", tag)
+	}
+}
@@ -0,0 +1,269 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultEndpointCooldown は失敗したエンドポイントを後回しにする期間のデフォルト値
+const defaultEndpointCooldown = 30 * time.Second
+
+// EndpointStatus はWorkerエンドポイントの状態
+type EndpointStatus struct {
+	// URL はエンドポイントのベースURL
+	URL string
+
+	// Healthy は直近の通信が成功しているか、クールダウンが明けている場合にtrue
+	Healthy bool
+
+	// Failures は連続した失敗回数
+	Failures int
+
+	// LastError は直近の失敗の原因
+	LastError error
+
+	// RetryAt はクールダウンが明ける日時（Healthy=falseの場合のみ）
+	RetryAt time.Time
+}
+
+// endpoint は1つのWorkerエンドポイントとその状態
+type endpoint struct {
+	url string
+
+	mu        sync.Mutex
+	failures  int
+	lastErr   error
+	downUntil time.Time
+}
+
+// endpointSet は優先順に並んだWorkerエンドポイントの集合
+type endpointSet struct {
+	endpoints []*endpoint
+	cooldown  time.Duration
+
+	mu         sync.Mutex
+	challenges map[string]challengeOrigin // チャレンジを発行したエンドポイント
+}
+
+// challengeOrigin はチャレンジを発行したエンドポイントと有効期限
+type challengeOrigin struct {
+	endpoint  *endpoint
+	expiresAt time.Time
+}
+
+// newEndpointSet はベースURLのリストからエンドポイントの集合を作成します
+func newEndpointSet(baseURLs []string, cooldown time.Duration) *endpointSet {
+	if cooldown <= 0 {
+		cooldown = defaultEndpointCooldown
+	}
+
+	set := &endpointSet{
+		cooldown:   cooldown,
+		challenges: make(map[string]challengeOrigin),
+	}
+	for _, baseURL := range baseURLs {
+		set.endpoints = append(set.endpoints, &endpoint{url: strings.TrimSuffix(baseURL, "/")})
+	}
+	return set
+}
+
+// candidates は試行する順にエンドポイントを返します
+// クールダウン中でないエンドポイントを設定順に並べ、その後にクールダウン中のものを続けます
+func (s *endpointSet) candidates(now time.Time) []*endpoint {
+	healthy := make([]*endpoint, 0, len(s.endpoints))
+	var down []*endpoint
+	for _, e := range s.endpoints {
+		if e.available(now) {
+			healthy = append(healthy, e)
+		} else {
+			down = append(down, e)
+		}
+	}
+	return append(healthy, down...)
+}
+
+// primary は現在最も優先されるエンドポイントを返します
+func (s *endpointSet) primary() *endpoint {
+	return s.candidates(time.Now())[0]
+}
+
+// rememberChallenge はチャレンジを発行したエンドポイントを記録します
+func (s *endpointSet) rememberChallenge(challenge string, e *endpoint, expiresAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 期限切れの記録を掃除
+	now := time.Now()
+	for key, origin := range s.challenges {
+		if now.After(origin.expiresAt) {
+			delete(s.challenges, key)
+		}
+	}
+	s.challenges[challenge] = challengeOrigin{endpoint: e, expiresAt: expiresAt}
+}
+
+// challengeEndpoint はチャレンジを発行したエンドポイントを返し、記録を削除します
+// 記録がない場合は現在最も優先されるエンドポイントを返します
+func (s *endpointSet) challengeEndpoint(challenge string) *endpoint {
+	s.mu.Lock()
+	origin, ok := s.challenges[challenge]
+	delete(s.challenges, challenge)
+	s.mu.Unlock()
+
+	if ok {
+		return origin.endpoint
+	}
+	return s.primary()
+}
+
+// available はエンドポイントがクールダウン中でないか判定します
+func (e *endpoint) available(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.downUntil)
+}
+
+// markFailure は失敗を記録し、クールダウンを開始します
+// 直前まで正常だった場合にtrueを返します
+func (e *endpoint) markFailure(err error, until time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	wasHealthy := e.failures == 0
+	e.failures++
+	e.lastErr = err
+	e.downUntil = until
+	return wasHealthy
+}
+
+// markSuccess は成功を記録し、失敗状態を解除します
+// 直前まで失敗状態だった場合にtrueを返します
+func (e *endpoint) markSuccess() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	recovered := e.failures > 0
+	e.failures = 0
+	e.lastErr = nil
+	e.downUntil = time.Time{}
+	return recovered
+}
+
+// status はエンドポイントの状態を返します
+func (e *endpoint) status(now time.Time) EndpointStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	status := EndpointStatus{
+		URL:       e.url,
+		Healthy:   !now.Before(e.downUntil),
+		Failures:  e.failures,
+		LastError: e.lastErr,
+	}
+	if !status.Healthy {
+		status.RetryAt = e.downUntil
+	}
+	return status
+}
+
+// record は通信結果をエンドポイントの状態に反映します
+// Workerに到達できない場合のみ失敗として扱い、認証拒否などは成功として扱います
+// キャンセルと証明書のピン不一致は状態を変更しません
+func (c *Client) record(e *endpoint, err error) {
+	switch {
+	case isContextError(err) || errors.Is(err, ErrCertificatePinMismatch):
+	case err == nil || !isWorkerUnreachable(err):
+		if e.markSuccess() {
+			c.logger.Info("endpoint recovered", "endpoint", e.url)
+		}
+	default:
+		if e.markFailure(err, time.Now().Add(c.endpoints.cooldown)) {
+			c.logger.Warn("endpoint marked unhealthy", "endpoint", e.url, "error", err)
+		}
+	}
+}
+
+// withFailover は優先順にエンドポイントを試し、Workerに到達できない場合は次のエンドポイントに切り替えます
+func withFailover[T any](c *Client, ctx context.Context, fn func(e *endpoint) (T, error)) (T, error) {
+	var zero T
+	var lastErr error
+
+	candidates := c.endpoints.candidates(time.Now())
+	for i, e := range candidates {
+		result, err := fn(e)
+		c.record(e, err)
+		if err == nil {
+			return result, nil
+		}
+		if !isWorkerUnreachable(err) || ctx.Err() != nil {
+			return zero, err
+		}
+
+		lastErr = err
+		if i < len(candidates)-1 {
+			c.logger.Warn("failing over to next endpoint",
+				"client_id", c.clientID, "endpoint", e.url, "next", candidates[i+1].url, "error", err)
+		}
+	}
+	return zero, lastErr
+}
+
+// Endpoints は設定された全てのWorkerエンドポイントの状態を設定順に返します
+func (c *Client) Endpoints() []EndpointStatus {
+	now := time.Now()
+	statuses := make([]EndpointStatus, len(c.endpoints.endpoints))
+	for i, e := range c.endpoints.endpoints {
+		statuses[i] = e.status(now)
+	}
+	return statuses
+}
+
+// CheckEndpoints は全てのエンドポイントの/healthを確認し、状態を更新して返します
+// /healthが200以外を返したエンドポイントも異常として扱います
+func (c *Client) CheckEndpoints(ctx context.Context) []EndpointStatus {
+	var wg sync.WaitGroup
+	for _, e := range c.endpoints.endpoints {
+		wg.Add(1)
+		go func(e *endpoint) {
+			defer wg.Done()
+			_, err := c.healthOn(ctx, e)
+			switch {
+			case err == nil:
+				c.record(e, nil)
+			case isContextError(err):
+			default:
+				if e.markFailure(err, time.Now().Add(c.endpoints.cooldown)) {
+					c.logger.Warn("endpoint marked unhealthy", "endpoint", e.url, "error", err)
+				}
+			}
+		}(e)
+	}
+	wg.Wait()
+
+	return c.Endpoints()
+}
+
+// endpointURL はエンドポイントのベースURLとパスを連結します
+func endpointURL(e *endpoint, format string, args ...any) string {
+	return e.url + fmt.Sprintf(format, args...)
+}
+
+// healthOn は指定したエンドポイントのヘルスチェックを実行します
+func (c *Client) healthOn(ctx context.Context, e *endpoint) (*HealthResponse, error) {
+	body, err := c.doRequest(ctx, http.MethodGet, endpointURL(e, "/health"), nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var healthResp HealthResponse
+	if err := c.decodeResponse("/health", body, &healthResp); err != nil {
+		return nil, err
+	}
+
+	return &healthResp, nil
+}
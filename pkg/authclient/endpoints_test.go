@@ -0,0 +1,261 @@
+package authclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// endpointServer は自身が発行したチャレンジのみ検証するテスト用Worker
+type endpointServer struct {
+	*httptest.Server
+	challenges atomic.Int32
+	verifies   atomic.Int32
+	fail       atomic.Bool // trueの間は全てのリクエストに503を返す
+}
+
+func newEndpointServer(t *testing.T, name string) *endpointServer {
+	t.Helper()
+
+	s := &endpointServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/challenge", func(w http.ResponseWriter, r *http.Request) {
+		if s.fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		s.challenges.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ChallengeResponse{
+			Challenge: "challenge-" + name,
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		})
+	})
+	mux.HandleFunc("/verify", func(w http.ResponseWriter, r *http.Request) {
+		if s.fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		s.verifies.Add(1)
+
+		var req VerifyRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Challenge != "challenge-"+name {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "unknown challenge"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(VerifyResponse{
+			Success:    true,
+			Token:      "token-" + name,
+			SecretData: map[string]string{"ENDPOINT": name},
+		})
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if s.fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(HealthResponse{Status: "ok"})
+	})
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func newFailoverClient(t *testing.T, baseURLs ...string) *Client {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	client, err := NewClient(ClientConfig{
+		BaseURLs:         baseURLs,
+		ClientID:         "test-client",
+		PrivateKey:       privateKey,
+		EndpointCooldown: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
+
+func TestNewClient_BaseURLs(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		config  ClientConfig
+		wantErr bool
+	}{
+		{
+			name:   "multiple base URLs",
+			config: ClientConfig{BaseURLs: []string{"https://a.example.com", "https://b.example.com/"}},
+		},
+		{
+			name:    "both baseURL and baseURLs",
+			config:  ClientConfig{BaseURL: "https://a.example.com", BaseURLs: []string{"https://b.example.com"}},
+			wantErr: true,
+		},
+		{
+			name:    "empty entry",
+			config:  ClientConfig{BaseURLs: []string{"https://a.example.com", ""}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.ClientID = "test-client"
+			tt.config.PrivateKey = privateKey
+
+			_, err := NewClient(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("error = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_FailsOverToNextEndpoint(t *testing.T) {
+	primary := newEndpointServer(t, "primary")
+	defer primary.Close()
+	secondary := newEndpointServer(t, "secondary")
+	defer secondary.Close()
+
+	client := newFailoverClient(t, primary.URL, secondary.URL)
+
+	resp, err := client.Authenticate()
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if resp.SecretData["ENDPOINT"] != "primary" {
+		t.Fatalf("ENDPOINT = %q, want primary", resp.SecretData["ENDPOINT"])
+	}
+
+	primary.fail.Store(true)
+
+	resp, err = client.Authenticate()
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if resp.SecretData["ENDPOINT"] != "secondary" {
+		t.Errorf("ENDPOINT = %q, want secondary", resp.SecretData["ENDPOINT"])
+	}
+
+	statuses := client.Endpoints()
+	if statuses[0].Healthy || statuses[0].Failures != 1 || statuses[0].LastError == nil {
+		t.Errorf("primary status = %+v, want unhealthy with 1 failure", statuses[0])
+	}
+	if !statuses[1].Healthy {
+		t.Errorf("secondary status = %+v, want healthy", statuses[1])
+	}
+
+	// クールダウン中は失敗したエンドポイントを後回しにする
+	primary.fail.Store(false)
+	before := primary.challenges.Load()
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if got := primary.challenges.Load(); got != before {
+		t.Errorf("primary received %d challenges during cooldown, want 0", got-before)
+	}
+}
+
+func TestAuthenticate_AllEndpointsDown(t *testing.T) {
+	primary := newEndpointServer(t, "primary")
+	primary.Close()
+	secondary := newEndpointServer(t, "secondary")
+	secondary.fail.Store(true)
+	defer secondary.Close()
+
+	client := newFailoverClient(t, primary.URL, secondary.URL)
+
+	_, err := client.Authenticate()
+	if !isWorkerUnreachable(err) {
+		t.Fatalf("Authenticate() error = %v, want worker unreachable", err)
+	}
+	for _, status := range client.Endpoints() {
+		if status.Healthy {
+			t.Errorf("status = %+v, want unhealthy", status)
+		}
+	}
+}
+
+func TestVerifySignature_UsesIssuingEndpoint(t *testing.T) {
+	primary := newEndpointServer(t, "primary")
+	defer primary.Close()
+	secondary := newEndpointServer(t, "secondary")
+	defer secondary.Close()
+
+	client := newFailoverClient(t, primary.URL, secondary.URL)
+
+	// プライマリが落ちている間にセカンダリからチャレンジを取得
+	primary.fail.Store(true)
+	challenge, err := client.RequestChallenge()
+	if err != nil {
+		t.Fatalf("RequestChallenge() error = %v", err)
+	}
+
+	// プライマリの状態を回復させても、検証はチャレンジを発行したセカンダリで行う
+	primary.fail.Store(false)
+	client.CheckEndpoints(context.Background())
+
+	signature, err := client.signChallenge(challenge.Challenge)
+	if err != nil {
+		t.Fatalf("signChallenge() error = %v", err)
+	}
+	resp, err := client.VerifySignature(challenge.Challenge, signature)
+	if err != nil {
+		t.Fatalf("VerifySignature() error = %v", err)
+	}
+	if resp.SecretData["ENDPOINT"] != "secondary" {
+		t.Errorf("ENDPOINT = %q, want secondary", resp.SecretData["ENDPOINT"])
+	}
+	if got := primary.verifies.Load(); got != 0 {
+		t.Errorf("primary received %d verify requests, want 0", got)
+	}
+}
+
+func TestCheckEndpoints(t *testing.T) {
+	primary := newEndpointServer(t, "primary")
+	defer primary.Close()
+	secondary := newEndpointServer(t, "secondary")
+	defer secondary.Close()
+
+	client := newFailoverClient(t, primary.URL, secondary.URL)
+
+	primary.fail.Store(true)
+	statuses := client.CheckEndpoints(context.Background())
+	if statuses[0].Healthy || statuses[0].RetryAt.IsZero() {
+		t.Errorf("primary status = %+v, want unhealthy", statuses[0])
+	}
+	if !statuses[1].Healthy {
+		t.Errorf("secondary status = %+v, want healthy", statuses[1])
+	}
+
+	primary.fail.Store(false)
+	statuses = client.CheckEndpoints(context.Background())
+	if !statuses[0].Healthy || statuses[0].Failures != 0 {
+		t.Errorf("primary status = %+v, want recovered", statuses[0])
+	}
+}
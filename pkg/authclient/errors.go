@@ -3,6 +3,7 @@ package authclient
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 var (
@@ -15,6 +16,12 @@ var (
 	// ErrNotFound はエンドポイント未発見エラー（404）
 	ErrNotFound = errors.New("endpoint not found")
 
+	// ErrForbidden は権限不足エラー（403）
+	ErrForbidden = errors.New("forbidden")
+
+	// ErrTunnelNotFound はトンネルが登録されていない場合のエラー（ErrNotFoundも満たします）
+	ErrTunnelNotFound = errors.New("tunnel not found")
+
 	// ErrInternalServer はサーバーエラー（500）
 	ErrInternalServer = errors.New("internal server error")
 
@@ -32,6 +39,18 @@ var (
 
 	// ErrNetworkError はネットワークエラー
 	ErrNetworkError = errors.New("network error")
+
+	// ErrInsecureTransport はStrictTransportでHTTPS以外のURLが指定された場合のエラー
+	ErrInsecureTransport = errors.New("insecure transport: baseURL must use HTTPS")
+
+	// ErrStaleSecrets はWorkerに到達できずキャッシュのSecret変数しか得られなかった場合のエラー
+	ErrStaleSecrets = errors.New("worker unreachable: only cached secrets available")
+
+	// ErrCertificatePinMismatch はWorkerの証明書がピン留めされた公開鍵と一致しない場合のエラー
+	ErrCertificatePinMismatch = errors.New("certificate pin mismatch")
+
+	// ErrProtocol はWorkerのレスポンスが期待する形式でない場合のエラー（*ProtocolErrorが該当）
+	ErrProtocol = errors.New("protocol error")
 )
 
 // HTTPError はHTTPステータスコードを含むエラー
@@ -39,6 +58,9 @@ type HTTPError struct {
 	StatusCode int
 	Message    string
 	Err        error
+
+	// RetryAfter はRetry-Afterヘッダーで指定された待機時間（指定なしの場合は0）
+	RetryAfter time.Duration
 }
 
 func (e *HTTPError) Error() string {
@@ -60,3 +82,32 @@ func NewHTTPError(statusCode int, message string, err error) *HTTPError {
 		Err:        err,
 	}
 }
+
+// ProtocolError はWorkerのレスポンスが期待する形式でない場合のエラー
+// サイズ超過・Content-Type不正・JSON不正・必須フィールドの欠落が該当します
+type ProtocolError struct {
+	// Path はリクエストしたエンドポイントのパス（例: /challenge）
+	Path string
+
+	// Reason は不正の内容
+	Reason string
+
+	// Err は原因となったエラー（ない場合はnil）
+	Err error
+}
+
+func (e *ProtocolError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%v on %s: %s: %v", ErrProtocol, e.Path, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("%v on %s: %s", ErrProtocol, e.Path, e.Reason)
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+// Is はerrors.Is(err, ErrProtocol)を満たすために使用されます
+func (e *ProtocolError) Is(target error) bool {
+	return target == ErrProtocol
+}
@@ -0,0 +1,90 @@
+package authclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PerRPCCredentialsConfig はPerRPCCredentialsの設定
+type PerRPCCredentialsConfig struct {
+	// TokenManager はトークンの取得元（オプション）
+	// 指定した場合は有効期限前の更新をTokenManagerに任せ、期限切れのトークンは送信前に更新します
+	// nilの場合はClientのアクセストークンを使用し、未取得・期限切れであれば認証します
+	TokenManager *TokenManager
+
+	// AllowInsecure がtrueの場合はTLSなしの接続でもトークンを送信します（ローカル開発用）
+	AllowInsecure bool
+}
+
+// PerRPCCredentials はgRPCのcredentials.PerRPCCredentialsと同じメソッドを持つ認証情報
+// このパッケージはgRPCに依存しませんが、構造的に一致するためそのまま渡せます
+//
+//	creds := authclient.NewPerRPCCredentials(client, authclient.PerRPCCredentialsConfig{})
+//	conn, err := grpc.NewClient(target,
+//	    grpc.WithTransportCredentials(credentials.NewTLS(nil)),
+//	    grpc.WithPerRPCCredentials(creds),
+//	)
+type PerRPCCredentials struct {
+	client        *Client
+	tokenManager  *TokenManager
+	allowInsecure bool
+}
+
+// NewPerRPCCredentials は新しいPerRPCCredentialsを作成します
+func NewPerRPCCredentials(client *Client, config PerRPCCredentialsConfig) *PerRPCCredentials {
+	return &PerRPCCredentials{
+		client:        client,
+		tokenManager:  config.TokenManager,
+		allowInsecure: config.AllowInsecure,
+	}
+}
+
+// GetRequestMetadata は各RPCに付与するauthorizationメタデータを返します
+func (p *PerRPCCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := p.token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity はトークンの送信にTLSが必要かどうかを返します
+func (p *PerRPCCredentials) RequireTransportSecurity() bool {
+	return !p.allowInsecure
+}
+
+// Refresh はトークンを即座に更新します
+// サーバーがUnauthenticatedを返した場合に呼び出すと、次のRPCから新しいトークンを送信します
+func (p *PerRPCCredentials) Refresh(ctx context.Context) error {
+	if p.tokenManager != nil {
+		return p.tokenManager.Refresh(ctx)
+	}
+	return p.client.RenewAccessToken(ctx)
+}
+
+// token は送信するアクセストークンを返し、未取得・期限切れの場合は更新します
+func (p *PerRPCCredentials) token(ctx context.Context) (string, error) {
+	if p.tokenManager != nil {
+		token := p.tokenManager.Token()
+		if token != "" && time.Now().Before(p.tokenManager.ExpiresAt()) {
+			return token, nil
+		}
+		if err := p.tokenManager.Refresh(ctx); err != nil {
+			return "", err
+		}
+		return p.tokenManager.Token(), nil
+	}
+
+	// 有効期限が不明なトークンはそのまま使用する
+	if token := p.client.GetAccessToken(); token != "" {
+		expiresAt := p.client.GetAccessTokenExpiresAt()
+		if expiresAt.IsZero() || time.Now().Before(expiresAt) {
+			return token, nil
+		}
+	}
+	if err := p.client.RenewAccessToken(ctx); err != nil {
+		return "", err
+	}
+	return p.client.GetAccessToken(), nil
+}
@@ -0,0 +1,137 @@
+package authclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/authtest"
+)
+
+// perRPCCredentials はgoogle.golang.org/grpc/credentials.PerRPCCredentialsと同じメソッドセット
+type perRPCCredentials interface {
+	GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error)
+	RequireTransportSecurity() bool
+}
+
+var _ perRPCCredentials = (*PerRPCCredentials)(nil)
+
+func setupCredentialsClient(t *testing.T) (*authtest.Server, *Client) {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	server := authtest.NewServer(authtest.Config{})
+	t.Cleanup(server.Close)
+	server.AddClient("test-client", &privateKey.PublicKey, nil)
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:      server.URL,
+		ClientID:     "test-client",
+		PrivateKey:   privateKey,
+		GrpcEndpoint: "grpc.example.com:443",
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return server, client
+}
+
+func TestPerRPCCredentials_AuthenticatesOnFirstUse(t *testing.T) {
+	server, client := setupCredentialsClient(t)
+	creds := NewPerRPCCredentials(client, PerRPCCredentialsConfig{})
+	ctx := context.Background()
+
+	if !creds.RequireTransportSecurity() {
+		t.Error("RequireTransportSecurity() should be true by default")
+	}
+
+	md, err := creds.GetRequestMetadata(ctx, "https://grpc.example.com/pkg.Service")
+	if err != nil {
+		t.Fatalf("GetRequestMetadata() error = %v", err)
+	}
+	first := server.AccessToken("test-client")
+	if md["authorization"] != "Bearer "+first {
+		t.Errorf("authorization = %q, want Bearer %q", md["authorization"], first)
+	}
+
+	// 2回目以降は認証しない
+	if _, err := creds.GetRequestMetadata(ctx); err != nil {
+		t.Fatalf("GetRequestMetadata() error = %v", err)
+	}
+	if n := server.Requests("/verify"); n != 1 {
+		t.Errorf("verify requests = %d, want 1", n)
+	}
+	if req, _ := server.LastVerifyRequest("test-client"); req.GrpcEndpoint != "grpc.example.com:443" {
+		t.Errorf("grpcEndpoint = %q", req.GrpcEndpoint)
+	}
+
+	if err := creds.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	md, _ = creds.GetRequestMetadata(ctx)
+	if second := server.AccessToken("test-client"); second == first || md["authorization"] != "Bearer "+second {
+		t.Errorf("authorization after Refresh = %q, server issued %q", md["authorization"], second)
+	}
+}
+
+func TestPerRPCCredentials_ReauthenticatesExpiredToken(t *testing.T) {
+	server, client := setupCredentialsClient(t)
+	creds := NewPerRPCCredentials(client, PerRPCCredentialsConfig{})
+	ctx := context.Background()
+
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	first := client.GetAccessToken()
+	if expiresAt := client.GetAccessTokenExpiresAt(); !expiresAt.After(time.Now()) {
+		t.Fatalf("GetAccessTokenExpiresAt() = %v, want a future time", expiresAt)
+	}
+
+	// 有効期限が過ぎたトークンは送信前に更新する
+	client.storeAccessToken(first, time.Now().Add(-time.Second))
+	md, err := creds.GetRequestMetadata(ctx)
+	if err != nil {
+		t.Fatalf("GetRequestMetadata() error = %v", err)
+	}
+	second := server.AccessToken("test-client")
+	if second == first || md["authorization"] != "Bearer "+second {
+		t.Errorf("authorization = %q, server issued %q", md["authorization"], second)
+	}
+	if n := server.Requests("/verify"); n != 2 {
+		t.Errorf("verify requests = %d, want 2", n)
+	}
+
+	// 有効期限が不明なトークンはそのまま使用する
+	client.SetAccessToken("manual-token")
+	if md, _ := creds.GetRequestMetadata(ctx); md["authorization"] != "Bearer manual-token" {
+		t.Errorf("authorization = %q, want manual-token", md["authorization"])
+	}
+}
+
+func TestPerRPCCredentials_TokenManager(t *testing.T) {
+	server, client := setupCredentialsClient(t)
+	manager := NewTokenManager(client, TokenManagerConfig{})
+	creds := NewPerRPCCredentials(client, PerRPCCredentialsConfig{
+		TokenManager:  manager,
+		AllowInsecure: true,
+	})
+
+	if creds.RequireTransportSecurity() {
+		t.Error("RequireTransportSecurity() should be false with AllowInsecure")
+	}
+
+	// 開始前のTokenManagerでも送信前にトークンを取得する
+	md, err := creds.GetRequestMetadata(context.Background())
+	if err != nil {
+		t.Fatalf("GetRequestMetadata() error = %v", err)
+	}
+	if md["authorization"] != "Bearer "+server.AccessToken("test-client") || manager.Token() == "" {
+		t.Errorf("authorization = %q, manager token = %q", md["authorization"], manager.Token())
+	}
+}
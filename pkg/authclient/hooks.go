@@ -0,0 +1,190 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// Hooks は認証フローの各段階で呼ばれるコールバック
+// 全てのフィールドは任意で、nilのコールバックは呼ばれません
+// Before系のコールバックが返したcontextは、その段階のHTTPリクエストと対応するAfter系に渡されます
+// （トレーシングのスパンをcontextに格納する用途を想定）
+type Hooks struct {
+	// BeforeChallenge はチャレンジ取得の前に呼ばれます
+	BeforeChallenge func(ctx context.Context, ev ChallengeEvent) context.Context
+
+	// AfterChallenge はチャレンジ取得の後に呼ばれます（ExpiresAt・Duration・Errが設定されます）
+	AfterChallenge func(ctx context.Context, ev ChallengeEvent)
+
+	// BeforeSign はチャレンジへの署名の前に呼ばれます
+	BeforeSign func(ctx context.Context, ev SignEvent) context.Context
+
+	// AfterSign はチャレンジへの署名の後に呼ばれます（Duration・Errが設定されます）
+	AfterSign func(ctx context.Context, ev SignEvent)
+
+	// BeforeVerify は署名の検証リクエストの前に呼ばれます
+	BeforeVerify func(ctx context.Context, ev VerifyEvent) context.Context
+
+	// AfterVerify は署名の検証リクエストの後に呼ばれます（StatusCode・Duration・Errが設定されます）
+	AfterVerify func(ctx context.Context, ev VerifyEvent)
+
+	// OnRetry はリトライ待機の前に呼ばれます
+	OnRetry func(ctx context.Context, ev RetryEvent)
+
+	// OnTokenStored は検証で受け取ったアクセストークンを保存した後に呼ばれます
+	// トークンの値は渡されません
+	OnTokenStored func(ctx context.Context, ev TokenEvent)
+
+	// ClientTrace はWorkerへの全てのHTTPリクエストに付与されるhttptrace
+	ClientTrace *httptrace.ClientTrace
+}
+
+// ChallengeEvent はチャレンジ取得に関する情報
+type ChallengeEvent struct {
+	ClientID  string
+	Endpoint  string
+	ExpiresAt time.Time
+	Duration  time.Duration
+	Err       error
+}
+
+// SignEvent はチャレンジへの署名に関する情報
+type SignEvent struct {
+	ClientID  string
+	Algorithm string
+	Duration  time.Duration
+	Err       error
+}
+
+// VerifyEvent は署名の検証に関する情報
+type VerifyEvent struct {
+	ClientID string
+	Endpoint string
+
+	// StatusCode はWorkerのHTTPステータス（Workerに到達できなかった場合は0）
+	StatusCode int
+
+	Duration time.Duration
+	Err      error
+}
+
+// RetryEvent はリトライに関する情報
+type RetryEvent struct {
+	ClientID string
+	Attempt  int
+	Err      error
+	Delay    time.Duration
+}
+
+// TokenEvent はアクセストークンの保存に関する情報
+type TokenEvent struct {
+	ClientID string
+
+	// ExpiresAt はWorkerが返したトークンの有効期限（返されない場合はゼロ値）
+	ExpiresAt time.Time
+}
+
+// challengeOn はフックを呼び出しながら指定したエンドポイントからチャレンジを取得します
+func (c *Client) challengeOn(ctx context.Context, e *endpoint) (*ChallengeResponse, error) {
+	ev := ChallengeEvent{ClientID: c.clientID, Endpoint: e.url}
+	if c.hooks.BeforeChallenge != nil {
+		ctx = orContext(c.hooks.BeforeChallenge(ctx, ev), ctx)
+	}
+
+	start := time.Now()
+	challengeResp, err := c.fetchChallenge(ctx, e)
+
+	if c.hooks.AfterChallenge != nil {
+		ev.Duration = time.Since(start)
+		ev.Err = err
+		if challengeResp != nil {
+			ev.ExpiresAt = time.Unix(challengeResp.ExpiresAt, 0)
+		}
+		c.hooks.AfterChallenge(ctx, ev)
+	}
+	return challengeResp, err
+}
+
+// signOn はフックを呼び出しながらチャレンジに署名します
+func (c *Client) signOn(ctx context.Context, challenge string) (string, error) {
+	ev := SignEvent{ClientID: c.clientID, Algorithm: string(c.algorithm)}
+	if c.hooks.BeforeSign != nil {
+		ctx = orContext(c.hooks.BeforeSign(ctx, ev), ctx)
+	}
+
+	start := time.Now()
+	signature, err := c.signChallenge(challenge)
+
+	if c.hooks.AfterSign != nil {
+		ev.Duration = time.Since(start)
+		ev.Err = err
+		c.hooks.AfterSign(ctx, ev)
+	}
+	return signature, err
+}
+
+// verifyOn はフックを呼び出しながら指定したエンドポイントで署名を検証します
+func (c *Client) verifyOn(ctx context.Context, e *endpoint, challenge, signature string) (*VerifyResponse, error) {
+	ev := VerifyEvent{ClientID: c.clientID, Endpoint: e.url}
+	if c.hooks.BeforeVerify != nil {
+		ctx = orContext(c.hooks.BeforeVerify(ctx, ev), ctx)
+	}
+
+	start := time.Now()
+	verifyResp, err := c.postVerify(ctx, e, challenge, signature)
+
+	if c.hooks.AfterVerify != nil {
+		ev.Duration = time.Since(start)
+		ev.StatusCode = responseStatus(err)
+		ev.Err = err
+		c.hooks.AfterVerify(ctx, ev)
+	}
+	return verifyResp, err
+}
+
+// retry はOnRetryフックを呼び出します
+func (h *Hooks) retry(ctx context.Context, ev RetryEvent) {
+	if h.OnRetry != nil {
+		h.OnRetry(ctx, ev)
+	}
+}
+
+// tokenStored はOnTokenStoredフックを呼び出します
+func (h *Hooks) tokenStored(ctx context.Context, ev TokenEvent) {
+	if h.OnTokenStored != nil {
+		h.OnTokenStored(ctx, ev)
+	}
+}
+
+// orContext はフックがnilを返した場合に元のcontextを使います
+func orContext(ctx, fallback context.Context) context.Context {
+	if ctx == nil {
+		return fallback
+	}
+	return ctx
+}
+
+// responseStatus はエラーからWorkerのHTTPステータスを求めます
+// Workerに到達できなかった場合は0、200でsuccess=falseが返された場合は200になります
+func responseStatus(err error) int {
+	var httpErr *HTTPError
+	switch {
+	case errors.As(err, &httpErr):
+		return httpErr.StatusCode
+	case errors.Is(err, ErrNetworkError), errors.Is(err, ErrCertificatePinMismatch), isContextError(err):
+		return 0
+	default:
+		return http.StatusOK
+	}
+}
+
+// unixTime はUnix時間を変換します（0以下はゼロ値）
+func unixTime(sec int64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
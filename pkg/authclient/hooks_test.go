@@ -0,0 +1,134 @@
+package authclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptrace"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type hookKey struct{}
+
+func TestHooks_CalledInOrder(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var fail atomic.Bool
+	fail.Store(true)
+	server, _ := setupTokenServer(t, &fail)
+	defer server.Close()
+
+	var calls []string
+	var conns atomic.Int32
+	var verifyEvent VerifyEvent
+	hooks := Hooks{
+		BeforeChallenge: func(ctx context.Context, ev ChallengeEvent) context.Context {
+			calls = append(calls, "before_challenge")
+			return context.WithValue(ctx, hookKey{}, "span")
+		},
+		AfterChallenge: func(ctx context.Context, ev ChallengeEvent) {
+			if ctx.Value(hookKey{}) != "span" {
+				t.Error("AfterChallenge did not receive context from BeforeChallenge")
+			}
+			if ev.Err != nil {
+				calls = append(calls, "after_challenge_error")
+				return
+			}
+			if ev.ExpiresAt.IsZero() {
+				t.Error("AfterChallenge ExpiresAt is zero")
+			}
+			calls = append(calls, "after_challenge")
+		},
+		BeforeSign: func(ctx context.Context, ev SignEvent) context.Context {
+			calls = append(calls, "before_sign")
+			return nil
+		},
+		AfterSign: func(ctx context.Context, ev SignEvent) {
+			if ev.Algorithm != AlgorithmRS256 || ev.Err != nil {
+				t.Errorf("AfterSign event = %+v", ev)
+			}
+			calls = append(calls, "after_sign")
+		},
+		BeforeVerify: func(ctx context.Context, ev VerifyEvent) context.Context {
+			calls = append(calls, "before_verify")
+			return ctx
+		},
+		AfterVerify: func(ctx context.Context, ev VerifyEvent) {
+			verifyEvent = ev
+			calls = append(calls, "after_verify")
+		},
+		OnRetry: func(ctx context.Context, ev RetryEvent) {
+			if ev.Attempt != 1 || ev.Err == nil {
+				t.Errorf("OnRetry event = %+v", ev)
+			}
+			calls = append(calls, "retry")
+			fail.Store(false)
+		},
+		OnTokenStored: func(ctx context.Context, ev TokenEvent) {
+			calls = append(calls, "token_stored")
+		},
+		ClientTrace: &httptrace.ClientTrace{
+			GotConn: func(httptrace.GotConnInfo) { conns.Add(1) },
+		},
+	}
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:    server.URL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+		Hooks:      hooks,
+		RetryPolicy: &ExponentialBackoff{
+			MaxRetries:      1,
+			InitialInterval: time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	want := []string{
+		"before_challenge", "after_challenge_error", "retry",
+		"before_challenge", "after_challenge",
+		"before_sign", "after_sign",
+		"before_verify", "token_stored", "after_verify",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if verifyEvent.StatusCode != http.StatusOK || verifyEvent.Err != nil || verifyEvent.Endpoint != server.URL {
+		t.Errorf("AfterVerify event = %+v", verifyEvent)
+	}
+	if got := conns.Load(); got != 3 {
+		t.Errorf("ClientTrace.GotConn called %d times, want 3", got)
+	}
+}
+
+func TestResponseStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, http.StatusOK},
+		{ErrUnauthorized, http.StatusOK},
+		{NewHTTPError(http.StatusUnauthorized, "bad", ErrUnauthorized), http.StatusUnauthorized},
+		{ErrNetworkError, 0},
+		{context.Canceled, 0},
+	}
+
+	for _, tt := range tests {
+		if got := responseStatus(tt.err); got != tt.want {
+			t.Errorf("responseStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package authclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// IntrospectToken は他のクライアントが提示したアクセストークンが有効かどうかをWorkerに問い合わせます
+// 自身のアクセストークンで認証するため、事前にAuthenticateを呼び出してください
+func (c *Client) IntrospectToken(token string) (*IntrospectResponse, error) {
+	return c.IntrospectTokenContext(context.Background(), token)
+}
+
+// IntrospectTokenContext はcontext付きでアクセストークンをWorkerに問い合わせます
+// 無効なトークンはエラーではなくActive=falseとして返します
+func (c *Client) IntrospectTokenContext(ctx context.Context, token string) (*IntrospectResponse, error) {
+	accessToken := c.GetAccessToken()
+	if accessToken == "" {
+		return nil, fmt.Errorf("%w: accessToken is required, please authenticate first", ErrInvalidConfig)
+	}
+
+	reqBody := IntrospectRequest{Token: token}
+	body, err := withFailover(c, ctx, func(e *endpoint) ([]byte, error) {
+		return c.doRequest(ctx, http.MethodPost, endpointURL(e, "/introspect"), reqBody, accessToken)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var introspectResp IntrospectResponse
+	if err := c.decodeResponse("/introspect", body, &introspectResp); err != nil {
+		return nil, err
+	}
+
+	if !introspectResp.Success {
+		return nil, fmt.Errorf("%w: %s", ErrUnauthorized, introspectResp.Error)
+	}
+
+	return &introspectResp, nil
+}
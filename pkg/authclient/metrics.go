@@ -0,0 +1,83 @@
+package authclient
+
+import (
+	"errors"
+	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/metrics"
+)
+
+// 認証試行の結果（authclient_auth_attempts_totalのoutcomeラベル）
+const (
+	outcomeSuccess          = "success"
+	outcomeUnauthorized     = "unauthorized"
+	outcomeChallengeExpired = "challenge_expired"
+	outcomeUnreachable      = "unreachable"
+	outcomeCanceled         = "canceled"
+	outcomeError            = "error"
+)
+
+// clientMetrics はクライアントが記録するメトリクス
+// レジストリ未指定の場合は各メトリクスがnilとなり、記録は何もしません
+type clientMetrics struct {
+	requestDuration *metrics.Histogram
+	attempts        *metrics.Counter
+	retries         *metrics.Counter
+	tokenStoredAt   *metrics.Gauge
+}
+
+// newClientMetrics はレジストリにクライアントのメトリクスを登録します
+// 同じレジストリを複数のクライアントで共有でき、client_idラベルで区別されます
+func newClientMetrics(registry *metrics.Registry) *clientMetrics {
+	return &clientMetrics{
+		requestDuration: registry.Histogram("authclient_request_duration_seconds",
+			"Latency of challenge and verify requests to the auth worker.",
+			nil, "client_id", "operation"),
+		attempts: registry.Counter("authclient_auth_attempts_total",
+			"Authentication attempts by outcome.",
+			"client_id", "outcome"),
+		retries: registry.Counter("authclient_retries_total",
+			"Authentication retries scheduled by the retry policy.",
+			"client_id"),
+		tokenStoredAt: registry.Gauge("authclient_token_stored_timestamp_seconds",
+			"Unix time when the current access token was stored (0 if none).",
+			"client_id"),
+	}
+}
+
+// observeRequest はWorkerへのリクエストのレイテンシを記録します
+func (m *clientMetrics) observeRequest(clientID, operation string, start time.Time) {
+	m.requestDuration.Observe(time.Since(start).Seconds(), clientID, operation)
+}
+
+// attemptOutcome は認証試行の結果をラベル値に分類します
+func attemptOutcome(err error) string {
+	switch {
+	case err == nil:
+		return outcomeSuccess
+	case isContextError(err):
+		return outcomeCanceled
+	case errors.Is(err, ErrChallengeExpired):
+		return outcomeChallengeExpired
+	case errors.Is(err, ErrUnauthorized), errors.Is(err, ErrInvalidSignature):
+		return outcomeUnauthorized
+	case isWorkerUnreachable(err):
+		return outcomeUnreachable
+	default:
+		return outcomeError
+	}
+}
+
+// recordTokenStored はアクセストークンを保存した日時を記録します（トークンがない場合は0）
+// 公開時に値を求める関数はクライアントを保持し続けるため、保存時に値として設定します
+func (m *clientMetrics) recordTokenStored(clientID string, storedAt time.Time) {
+	if m == nil {
+		return
+	}
+
+	var value float64
+	if !storedAt.IsZero() {
+		value = float64(storedAt.UnixNano()) / float64(time.Second)
+	}
+	m.tokenStoredAt.Set(value, clientID)
+}
@@ -0,0 +1,104 @@
+package authclient
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/metrics"
+)
+
+func TestClient_Metrics(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var fail atomic.Bool
+	fail.Store(true)
+	server, _ := setupTokenServer(t, &fail)
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	client, err := NewClient(ClientConfig{
+		BaseURL:    server.URL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+		Metrics:    registry,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	// 1回目は503、リトライで成功させる
+	client.SetRetryPolicy(&ExponentialBackoff{
+		MaxRetries:      1,
+		InitialInterval: time.Millisecond,
+		Retryable: func(err error) bool {
+			fail.Store(false)
+			return IsRetryable(err)
+		},
+	})
+
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	var b strings.Builder
+	registry.WriteText(&b)
+	text := b.String()
+
+	for _, want := range []string{
+		`authclient_auth_attempts_total{client_id="test-client",outcome="unreachable"} 1`,
+		`authclient_auth_attempts_total{client_id="test-client",outcome="success"} 1`,
+		`authclient_retries_total{client_id="test-client"} 1`,
+		`authclient_request_duration_seconds_count{client_id="test-client",operation="challenge"} 2`,
+		`authclient_request_duration_seconds_count{client_id="test-client",operation="verify"} 1`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("metrics missing %q:\n%s", want, text)
+		}
+	}
+
+	stored := regexp.MustCompile(`authclient_token_stored_timestamp_seconds\{client_id="test-client"\} (\S+)`).FindStringSubmatch(text)
+	if stored == nil {
+		t.Fatalf("token stored time not reported after authentication:\n%s", text)
+	}
+	if value, err := strconv.ParseFloat(stored[1], 64); err != nil || math.Abs(value-float64(time.Now().Unix())) > 60 {
+		t.Errorf("authclient_token_stored_timestamp_seconds = %s, want about now", stored[1])
+	}
+
+	// トークンを破棄すると0になる
+	client.SetAccessToken("")
+	b.Reset()
+	registry.WriteText(&b)
+	if want := `authclient_token_stored_timestamp_seconds{client_id="test-client"} 0`; !strings.Contains(b.String(), want) {
+		t.Errorf("metrics missing %q after clearing the token:\n%s", want, b.String())
+	}
+}
+
+func TestAttemptOutcome(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, outcomeSuccess},
+		{ErrUnauthorized, outcomeUnauthorized},
+		{ErrChallengeExpired, outcomeChallengeExpired},
+		{NewHTTPError(503, "unavailable", nil), outcomeUnreachable},
+		{ErrNetworkError, outcomeUnreachable},
+		{ErrBadRequest, outcomeError},
+	}
+
+	for _, tt := range tests {
+		if got := attemptOutcome(tt.err); got != tt.want {
+			t.Errorf("attemptOutcome(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PeerResolverConfig はPeerResolverの設定
+type PeerResolverConfig struct {
+	// TTL は解決したトンネルURLをキャッシュする期間（デフォルト: 5分）
+	TTL time.Duration
+
+	// Base はピアへのリクエストを送信するRoundTripper（nilの場合はhttp.DefaultTransport）
+	Base http.RoundTripper
+
+	// AssertionTTL はピアに送信するピアアサーションの有効期間（デフォルト: 1分）
+	AssertionTTL time.Duration
+
+	// ForwardAccessToken がtrueの場合、ピアアサーションの代わりに自身のWorkerのアクセストークンを送信します
+	// 受け取ったピアはそのトークンで自身のトンネル登録の変更・削除やWorkerへの照会ができるため、
+	// 完全に信頼できるピアにのみ使用してください（ピア側ではIntrospectionValidatorで検証します）
+	ForwardAccessToken bool
+}
+
+// PeerResolver は他のクライアントのIDをWorkerに登録されたトンネルURLに解決します
+// 解決したURLはTTLの間キャッシュし、ピアへの接続に失敗した場合は登録を取り直します
+//
+// ピアにはWorkerのアクセストークンではなく、ピア宛てに署名したピアアサーションを送信します
+// ピア側のauthmiddlewareはPeerAssertionValidatorで呼び出し元を検証するよう設定する必要があります
+// （デフォルトの設定ではピア自身のトークンしか受け付けず、401になります）
+type PeerResolver struct {
+	client       *Client
+	ttl          time.Duration
+	base         http.RoundTripper
+	assertionTTL time.Duration
+	forwardToken bool
+
+	mu         sync.Mutex
+	entries    map[string]peerEntry
+	assertions map[string]peerAssertion
+}
+
+// peerEntry はキャッシュしたトンネルURL
+type peerEntry struct {
+	url       string
+	expiresAt time.Time
+}
+
+// peerAssertion はキャッシュしたピアアサーション
+type peerAssertion struct {
+	value     string
+	refreshAt time.Time
+}
+
+// NewPeerResolver は新しいPeerResolverを作成します
+func NewPeerResolver(client *Client, config PeerResolverConfig) *PeerResolver {
+	if config.TTL <= 0 {
+		config.TTL = 5 * time.Minute
+	}
+	if config.AssertionTTL <= 0 {
+		config.AssertionTTL = time.Minute
+	}
+
+	base := config.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &PeerResolver{
+		client:       client,
+		ttl:          config.TTL,
+		base:         base,
+		assertionTTL: config.AssertionTTL,
+		forwardToken: config.ForwardAccessToken,
+		entries:      make(map[string]peerEntry),
+		assertions:   make(map[string]peerAssertion),
+	}
+}
+
+// Resolve はピアの現在のトンネルURLを返します
+// キャッシュが有効な場合はWorkerに問い合わせません
+// ピアが未登録の場合はErrTunnelNotFound、Workerが参照を許可していない場合はErrForbiddenを返します
+func (r *PeerResolver) Resolve(ctx context.Context, clientID string) (string, error) {
+	r.mu.Lock()
+	entry, ok := r.entries[clientID]
+	r.mu.Unlock()
+
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.url, nil
+	}
+	return r.Refresh(ctx, clientID)
+}
+
+// Refresh はキャッシュを使わずにWorkerからピアのトンネルURLを取得し直します
+func (r *PeerResolver) Refresh(ctx context.Context, clientID string) (string, error) {
+	tunnel, err := r.lookup(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, ErrTunnelNotFound) {
+			r.Invalidate(clientID)
+		}
+		return "", err
+	}
+	if tunnel.TunnelUrl == "" {
+		return "", fmt.Errorf("%w: peer %s has no tunnel URL", ErrTunnelNotFound, clientID)
+	}
+
+	r.mu.Lock()
+	previous := r.entries[clientID].url
+	r.entries[clientID] = peerEntry{url: tunnel.TunnelUrl, expiresAt: time.Now().Add(r.ttl)}
+	r.mu.Unlock()
+
+	if previous != "" && previous != tunnel.TunnelUrl {
+		r.client.logger.Info("peer tunnel changed",
+			"client_id", r.client.clientID, "peer_id", clientID, "tunnel_url", tunnel.TunnelUrl)
+	}
+
+	return tunnel.TunnelUrl, nil
+}
+
+// Invalidate はピアのキャッシュを破棄します
+func (r *PeerResolver) Invalidate(clientID string) {
+	r.mu.Lock()
+	delete(r.entries, clientID)
+	r.mu.Unlock()
+}
+
+// HTTPClient はピアにピアアサーション（ForwardAccessTokenの場合はアクセストークン）を付与して
+// リクエストを送信するhttp.Clientを返します
+// リクエストURLのスキームとホストはピアのトンネルURLに置き換えられ、パスはトンネルURLのパスに連結されます
+//
+//	resp, err := resolver.HTTPClient("other-client").Get("/api/status")
+//
+// 接続エラーや502・503・504・530が返された場合はトンネルURLを取り直し、
+// URLが変わっていればリクエストを1度だけ再送します
+// ピアが401を返しても再認証はせず、そのまま返します
+// （ForwardAccessTokenの場合に自身のトークンが入れ替わり、自身への接続を検証しているトークンまで変わってしまうため）
+func (r *PeerResolver) HTTPClient(clientID string) *http.Client {
+	return &http.Client{Transport: &peerTransport{resolver: r, peerID: clientID}}
+}
+
+// credential はピアに送信する資格情報を返します
+// ピアアサーションは有効期間の半分を過ぎるまで使い回します
+func (r *PeerResolver) credential(ctx context.Context, peerID string) (string, error) {
+	if r.forwardToken {
+		return r.accessToken(ctx)
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	cached, ok := r.assertions[peerID]
+	r.mu.Unlock()
+	if ok && now.Before(cached.refreshAt) {
+		return cached.value, nil
+	}
+
+	assertion, err := r.client.NewPeerAssertion(peerID, r.assertionTTL)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	r.assertions[peerID] = peerAssertion{value: assertion, refreshAt: now.Add(r.assertionTTL / 2)}
+	r.mu.Unlock()
+	return assertion, nil
+}
+
+// accessToken はピアに送信する自身のアクセストークンを返し、未取得の場合は認証します
+func (r *PeerResolver) accessToken(ctx context.Context) (string, error) {
+	if token := r.client.GetAccessToken(); token != "" {
+		return token, nil
+	}
+	if err := r.client.RenewAccessToken(ctx); err != nil {
+		return "", err
+	}
+	return r.client.GetAccessToken(), nil
+}
+
+// lookup はGetTunnelForでピアの登録を取得し、トークンが拒否された場合は再認証して1回だけやり直します
+func (r *PeerResolver) lookup(ctx context.Context, clientID string) (*TunnelData, error) {
+	if r.client.GetAccessToken() == "" {
+		if err := r.client.RenewAccessToken(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	tunnel, err := r.client.GetTunnelForContext(ctx, clientID)
+	if !errors.Is(err, ErrUnauthorized) {
+		return tunnel, err
+	}
+
+	r.client.logger.Info("access token rejected by tunnel lookup, re-authenticating",
+		"client_id", r.client.clientID)
+	if err := r.client.RenewAccessToken(ctx); err != nil {
+		return nil, err
+	}
+	return r.client.GetTunnelForContext(ctx, clientID)
+}
+
+// peerTransport はリクエストをピアのトンネルURLに向けるhttp.RoundTripper
+type peerTransport struct {
+	resolver *PeerResolver
+	peerID   string
+}
+
+// RoundTrip はhttp.RoundTripperの実装です
+func (t *peerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	baseURL, err := t.resolver.Resolve(ctx, t.peerID)
+	if err != nil {
+		closeRequestBody(req)
+		return nil, fmt.Errorf("failed to resolve peer %s: %w", t.peerID, err)
+	}
+
+	token, err := t.resolver.credential(ctx, t.peerID)
+	if err != nil {
+		closeRequestBody(req)
+		return nil, fmt.Errorf("failed to create peer credential: %w", err)
+	}
+
+	peerReq, err := peerRequest(req, baseURL, token, req.Body)
+	if err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+
+	resp, err := t.resolver.base.RoundTrip(peerReq)
+	if (err == nil && !isStalePeerStatus(resp.StatusCode)) || ctx.Err() != nil {
+		return resp, err
+	}
+
+	// トンネルが入れ替わっている可能性があるため登録を取り直す
+	newURL, refreshErr := t.resolver.Refresh(ctx, t.peerID)
+	if refreshErr != nil || newURL == baseURL || !isReplayable(req) {
+		return resp, err
+	}
+
+	var body io.ReadCloser
+	if req.GetBody != nil {
+		b, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return resp, err
+		}
+		body = b
+	}
+	retryReq, reqErr := peerRequest(req, newURL, token, body)
+	if reqErr != nil {
+		if body != nil {
+			body.Close()
+		}
+		return resp, err
+	}
+
+	if resp != nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	t.resolver.client.logger.Info("retrying request on refreshed peer tunnel",
+		"client_id", t.resolver.client.clientID, "peer_id", t.peerID)
+	return t.resolver.base.RoundTrip(retryReq)
+}
+
+// peerRequest はリクエストURLをピアのトンネルURLに書き換え、資格情報を付与した複製を作成します
+func peerRequest(req *http.Request, baseURL, token string, body io.ReadCloser) (*http.Request, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("%w: invalid peer tunnel URL %q", ErrInvalidConfig, baseURL)
+	}
+
+	clone := authorizedRequest(req, token, body)
+	clone.URL.Scheme = base.Scheme
+	clone.URL.Host = base.Host
+	clone.URL.Path = strings.TrimSuffix(base.Path, "/") + "/" + strings.TrimPrefix(req.URL.Path, "/")
+	clone.URL.RawPath = ""
+	clone.Host = ""
+	return clone, nil
+}
+
+// isStalePeerStatus はトンネルが停止・入れ替わった可能性のあるステータスかどうかを判定します
+// 530はCloudflareがトンネルに接続できない場合に返します
+func isStalePeerStatus(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout, 530:
+		return true
+	default:
+		return false
+	}
+}
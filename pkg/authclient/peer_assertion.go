@@ -0,0 +1,136 @@
+package authclient
+
+import (
+	gocrypto "crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/internal/crypto"
+)
+
+// PeerAssertionPrefix はピアアサーションの先頭に付く識別子
+const PeerAssertionPrefix = "peer."
+
+// ErrInvalidPeerAssertion はピアアサーションの形式・署名・宛先・有効期限が不正な場合のエラー
+var ErrInvalidPeerAssertion = errors.New("invalid peer assertion")
+
+// PeerAssertion はクライアントが自身の秘密鍵で署名した、特定のピア宛ての短命な資格情報
+// WorkerのアクセストークンとWorkerの権限を持たないため、ピアに送信してもWorkerへの操作には使えません
+//
+// 形式は "peer." + Base64URL(クレームのJSON) + "." + Base64(署名) で、
+// 署名は "peer." + Base64URL(クレームのJSON) に対してチャレンジと同じアルゴリズムで行います
+type PeerAssertion struct {
+	// Issuer は署名したクライアントID
+	Issuer string `json:"iss"`
+
+	// Audience は宛先のピアのクライアントID
+	Audience string `json:"aud"`
+
+	// Algorithm は署名アルゴリズム（RS256・PS256・ES256・EdDSA）
+	Algorithm string `json:"alg"`
+
+	// IssuedAt は発行時刻（Unix秒）
+	IssuedAt int64 `json:"iat"`
+
+	// ExpiresAt は有効期限（Unix秒）
+	ExpiresAt int64 `json:"exp"`
+
+	signingInput string
+	signature    string
+}
+
+// NewPeerAssertion はpeerID宛てのピアアサーションを自身の秘密鍵で署名して作成します
+// 有効期間はttlで、0以下の場合は1分です
+func (c *Client) NewPeerAssertion(peerID string, ttl time.Duration) (string, error) {
+	if peerID == "" {
+		return "", fmt.Errorf("%w: peer ID is required", ErrInvalidConfig)
+	}
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+
+	alg, err := c.signingAlgorithm()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims, err := json.Marshal(PeerAssertion{
+		Issuer:    c.clientID,
+		Audience:  peerID,
+		Algorithm: string(alg),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode peer assertion: %w", err)
+	}
+
+	signingInput := PeerAssertionPrefix + base64.RawURLEncoding.EncodeToString(claims)
+	signature, err := crypto.SignChallengeWithAlgorithm(c.signer, alg, signingInput)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign peer assertion: %w", err)
+	}
+	return signingInput + "." + signature, nil
+}
+
+// ParsePeerAssertion はピアアサーションを解析します
+// 署名・宛先・有効期限は検証しないため、VerifyPeerAssertionを使用してください
+func ParsePeerAssertion(assertion string) (*PeerAssertion, error) {
+	rest, ok := strings.CutPrefix(assertion, PeerAssertionPrefix)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %q prefix", ErrInvalidPeerAssertion, PeerAssertionPrefix)
+	}
+	encoded, signature, ok := strings.Cut(rest, ".")
+	if !ok || encoded == "" || signature == "" {
+		return nil, fmt.Errorf("%w: malformed assertion", ErrInvalidPeerAssertion)
+	}
+
+	claims, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode claims: %v", ErrInvalidPeerAssertion, err)
+	}
+
+	var parsed PeerAssertion
+	if err := json.Unmarshal(claims, &parsed); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse claims: %v", ErrInvalidPeerAssertion, err)
+	}
+	if parsed.Issuer == "" || parsed.Audience == "" {
+		return nil, fmt.Errorf("%w: missing issuer or audience", ErrInvalidPeerAssertion)
+	}
+
+	parsed.signingInput = PeerAssertionPrefix + encoded
+	parsed.signature = signature
+	return &parsed, nil
+}
+
+// VerifyPeerAssertion はピアアサーションを解析し、署名・宛先・有効期限を検証します
+// publicKeyはIssuerの公開鍵、audienceは自身のクライアントIDです
+// 発行から有効期限までがmaxLifetimeより長いもの、時刻がskew以上ずれているものは拒否します
+func VerifyPeerAssertion(assertion *PeerAssertion, publicKey gocrypto.PublicKey, audience string, maxLifetime, skew time.Duration) error {
+	if assertion.Audience != audience {
+		return fmt.Errorf("%w: audience %q does not match %q", ErrInvalidPeerAssertion, assertion.Audience, audience)
+	}
+
+	now := time.Now()
+	issuedAt := time.Unix(assertion.IssuedAt, 0)
+	expiresAt := time.Unix(assertion.ExpiresAt, 0)
+	switch {
+	case !expiresAt.After(issuedAt) || expiresAt.Sub(issuedAt) > maxLifetime:
+		return fmt.Errorf("%w: invalid lifetime", ErrInvalidPeerAssertion)
+	case issuedAt.After(now.Add(skew)):
+		return fmt.Errorf("%w: issued in the future", ErrInvalidPeerAssertion)
+	case !now.Before(expiresAt.Add(skew)):
+		return fmt.Errorf("%w: expired", ErrInvalidPeerAssertion)
+	}
+
+	err := crypto.VerifySignatureWithAlgorithm(publicKey, crypto.Algorithm(assertion.Algorithm), assertion.signingInput, assertion.signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPeerAssertion, err)
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package authclient
+
+import (
+	"context"
+	gocrypto "crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/authtest"
+)
+
+// setupPeer はWorkerにピアを登録し、トンネルURLを登録するクライアントと解決するクライアントを返します
+func setupPeer(t *testing.T) (*authtest.Server, *Client, *Client) {
+	t.Helper()
+
+	server := authtest.NewServer(authtest.Config{})
+	t.Cleanup(server.Close)
+
+	newTestClient := func(clientID string) *Client {
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		server.AddClient(clientID, &privateKey.PublicKey, nil)
+
+		client, err := NewClient(ClientConfig{
+			BaseURL:    server.URL,
+			ClientID:   clientID,
+			PrivateKey: privateKey,
+		})
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		return client
+	}
+
+	peer := newTestClient("peer-client")
+	if _, err := peer.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	return server, peer, newTestClient("test-client")
+}
+
+// newPeerService はAuthorizationヘッダーとパスを返すピアのサービスを起動します
+func newPeerService(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name + " " + r.URL.Path + " " + r.Header.Get("Authorization")))
+	}))
+	t.Cleanup(service.Close)
+	return service
+}
+
+func TestPeerResolver_HTTPClientFollowsTunnelChange(t *testing.T) {
+	server, peer, client := setupPeer(t)
+
+	first := newPeerService(t, "first")
+	if _, err := peer.RegisterTunnel(first.URL + "/base"); err != nil {
+		t.Fatalf("RegisterTunnel() error = %v", err)
+	}
+
+	resolver := NewPeerResolver(client, PeerResolverConfig{TTL: time.Hour})
+	httpClient := resolver.HTTPClient("peer-client")
+
+	get := func() string {
+		t.Helper()
+		resp, err := httpClient.Get("/api/status")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	// Workerのアクセストークンではなく、ピア宛てのピアアサーションを送信する
+	got := get()
+	assertion, ok := strings.CutPrefix(got, "first /base/api/status Bearer ")
+	if !ok {
+		t.Fatalf("response = %q", got)
+	}
+	if strings.Contains(got, server.AccessToken("test-client")) {
+		t.Error("worker access token was forwarded to the peer")
+	}
+	parsed, err := ParsePeerAssertion(assertion)
+	if err != nil {
+		t.Fatalf("ParsePeerAssertion() error = %v", err)
+	}
+	if err := VerifyPeerAssertion(parsed, client.PublicKey(), "peer-client", time.Minute, 0); err != nil {
+		t.Errorf("VerifyPeerAssertion() error = %v", err)
+	}
+
+	// トンネルが入れ替わり、古いURLに接続できなくなる
+	second := newPeerService(t, "second")
+	if _, err := peer.RegisterTunnel(second.URL); err != nil {
+		t.Fatalf("RegisterTunnel() error = %v", err)
+	}
+	first.Close()
+
+	if got := get(); !strings.HasPrefix(got, "second /api/status Bearer ") {
+		t.Errorf("response after tunnel change = %q", got)
+	}
+	if got, _ := resolver.Resolve(context.Background(), "peer-client"); got != second.URL {
+		t.Errorf("Resolve() = %q, want %q", got, second.URL)
+	}
+}
+
+func TestPeerResolver_CachesWithTTL(t *testing.T) {
+	server, peer, client := setupPeer(t)
+
+	if _, err := peer.RegisterTunnel("https://first.example.com"); err != nil {
+		t.Fatalf("RegisterTunnel() error = %v", err)
+	}
+
+	resolver := NewPeerResolver(client, PeerResolverConfig{TTL: 100 * time.Millisecond})
+	ctx := context.Background()
+
+	for range 3 {
+		got, err := resolver.Resolve(ctx, "peer-client")
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+		if got != "https://first.example.com" {
+			t.Errorf("Resolve() = %q", got)
+		}
+	}
+	if n := server.Requests("/tunnel/peer-client"); n != 1 {
+		t.Errorf("tunnel lookups = %d, want 1", n)
+	}
+
+	if _, err := peer.RegisterTunnel("https://second.example.com"); err != nil {
+		t.Fatalf("RegisterTunnel() error = %v", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	if got, _ := resolver.Resolve(ctx, "peer-client"); got != "https://second.example.com" {
+		t.Errorf("Resolve() after TTL = %q", got)
+	}
+}
+
+func TestPeerResolver_ReauthenticatesAndReportsNotFound(t *testing.T) {
+	server, _, client := setupPeer(t)
+
+	resolver := NewPeerResolver(client, PeerResolverConfig{})
+	ctx := context.Background()
+
+	if _, err := resolver.Resolve(ctx, "peer-client"); !errors.Is(err, ErrTunnelNotFound) {
+		t.Errorf("Resolve() error = %v, want ErrTunnelNotFound", err)
+	}
+
+	// トークンが失効していれば再認証してから参照する
+	server.RevokeAccessToken("test-client")
+	if _, err := resolver.Resolve(ctx, "peer-client"); !errors.Is(err, ErrTunnelNotFound) {
+		t.Errorf("Resolve() with revoked token error = %v, want ErrTunnelNotFound", err)
+	}
+	// ピアの認証・初回の認証・失効後の再認証
+	if n := server.Requests("/verify"); n != 3 {
+		t.Errorf("verify requests = %d, want 3", n)
+	}
+
+	if _, err := resolver.HTTPClient("peer-client").Get("/"); !errors.Is(err, ErrTunnelNotFound) {
+		t.Errorf("Get() error = %v, want ErrTunnelNotFound", err)
+	}
+}
+
+func TestPeerResolver_ForwardAccessToken(t *testing.T) {
+	server, peer, client := setupPeer(t)
+
+	service := newPeerService(t, "peer")
+	if _, err := peer.RegisterTunnel(service.URL); err != nil {
+		t.Fatalf("RegisterTunnel() error = %v", err)
+	}
+
+	resolver := NewPeerResolver(client, PeerResolverConfig{ForwardAccessToken: true})
+	resp, err := resolver.HTTPClient("peer-client").Get("/api/status")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if want := "peer /api/status Bearer " + server.AccessToken("test-client"); string(body) != want {
+		t.Errorf("response = %q, want %q", body, want)
+	}
+}
+
+func TestVerifyPeerAssertion(t *testing.T) {
+	_, peer, client := setupPeer(t)
+
+	assertion, err := client.NewPeerAssertion("peer-client", time.Minute)
+	if err != nil {
+		t.Fatalf("NewPeerAssertion() error = %v", err)
+	}
+	expired, err := client.NewPeerAssertion("peer-client", time.Second)
+	if err != nil {
+		t.Fatalf("NewPeerAssertion() error = %v", err)
+	}
+	// 署名済みのクレームを書き換えたもの
+	encoded, signature, _ := strings.Cut(strings.TrimPrefix(assertion, PeerAssertionPrefix), ".")
+	claims, _ := base64.RawURLEncoding.DecodeString(encoded)
+	forged := PeerAssertionPrefix + base64.RawURLEncoding.EncodeToString(
+		[]byte(strings.Replace(string(claims), "peer-client", "other-client", 1))) + "." + signature
+
+	tests := []struct {
+		name      string
+		assertion string
+		publicKey gocrypto.PublicKey
+		audience  string
+		wantErr   bool
+	}{
+		{name: "valid", assertion: assertion, publicKey: client.PublicKey(), audience: "peer-client"},
+		{name: "other audience", assertion: assertion, publicKey: client.PublicKey(), audience: "other-client", wantErr: true},
+		{name: "other key", assertion: assertion, publicKey: peer.PublicKey(), audience: "peer-client", wantErr: true},
+		{name: "forged audience", assertion: forged, publicKey: client.PublicKey(), audience: "other-client", wantErr: true},
+		{name: "expired", assertion: expired, publicKey: client.PublicKey(), audience: "peer-client", wantErr: true},
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParsePeerAssertion(tt.assertion)
+			if err == nil {
+				err = VerifyPeerAssertion(parsed, tt.publicKey, tt.audience, time.Minute, 0)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyPeerAssertion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidPeerAssertion) {
+				t.Errorf("VerifyPeerAssertion() error = %v, want ErrInvalidPeerAssertion", err)
+			}
+		})
+	}
+
+	if _, err := ParsePeerAssertion("not-an-assertion"); !errors.Is(err, ErrInvalidPeerAssertion) {
+		t.Errorf("ParsePeerAssertion() error = %v, want ErrInvalidPeerAssertion", err)
+	}
+}
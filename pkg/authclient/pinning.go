@@ -0,0 +1,127 @@
+package authclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SPKIHash は証明書のSubjectPublicKeyInfoのSHA-256ハッシュをBase64エンコードして返します
+// ClientConfig.PinnedSPKIHashesに指定する値です（HPKPのpin-sha256と同じ形式）
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// checkBaseURL はベースURLのスキームを検証します
+// strictがtrueの場合、ループバック以外へのHTTP接続を拒否します
+func checkBaseURL(baseURL string, strict bool) (insecure bool, err error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return false, fmt.Errorf("%w: invalid baseURL: %v", ErrInvalidConfig, err)
+	}
+
+	switch u.Scheme {
+	case "https":
+		return false, nil
+	case "http":
+		if isLoopbackHost(u.Hostname()) {
+			return false, nil
+		}
+		if strict {
+			return true, fmt.Errorf("%w: %s", ErrInsecureTransport, baseURL)
+		}
+		return true, nil
+	default:
+		return false, fmt.Errorf("%w: unsupported baseURL scheme %q", ErrInvalidConfig, u.Scheme)
+	}
+}
+
+// isLoopbackHost はホストがループバックアドレスかどうかを判定します
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// spkiPins はピン留めされた公開鍵ハッシュの集合
+type spkiPins map[string]struct{}
+
+// newSPKIPins はピンのリストから集合を作成します
+func newSPKIPins(hashes []string) (spkiPins, error) {
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+
+	pins := make(spkiPins, len(hashes))
+	for _, hash := range hashes {
+		decoded, err := base64.StdEncoding.DecodeString(hash)
+		if err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("%w: invalid SPKI pin %q", ErrInvalidConfig, hash)
+		}
+		pins[hash] = struct{}{}
+	}
+	return pins, nil
+}
+
+// verify は検証済みの証明書チェーンのいずれかの証明書がピンと一致するか確認します
+// サーバーが送ってきただけで検証経路に含まれない証明書（PeerCertificates）は照合しません
+// そのためInsecureSkipVerifyで証明書の検証を省略した接続は常に不一致になります
+func (p spkiPins) verify(state *tls.ConnectionState) error {
+	if state == nil {
+		return fmt.Errorf("%w: connection is not TLS", ErrCertificatePinMismatch)
+	}
+	if len(state.VerifiedChains) == 0 {
+		return fmt.Errorf("%w: certificate chain was not verified", ErrCertificatePinMismatch)
+	}
+
+	for _, chain := range state.VerifiedChains {
+		for _, cert := range chain {
+			if _, ok := p[SPKIHash(cert)]; ok {
+				return nil
+			}
+		}
+	}
+	return ErrCertificatePinMismatch
+}
+
+// pinnedHTTPClient はTLSハンドシェイク時にピンを検証するHTTPクライアントを作成します
+// 独自のRoundTripperが設定されている場合はハンドシェイク時の検証を行わず、
+// レスポンス受信時の検証のみになります
+func pinnedHTTPClient(httpClient *http.Client, pins spkiPins) *http.Client {
+	var base *http.Transport
+	switch transport := httpClient.Transport.(type) {
+	case nil:
+		base = http.DefaultTransport.(*http.Transport)
+	case *http.Transport:
+		base = transport
+	default:
+		return httpClient
+	}
+
+	transport := base.Clone()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	verifyConnection := transport.TLSClientConfig.VerifyConnection
+	transport.TLSClientConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if verifyConnection != nil {
+			if err := verifyConnection(state); err != nil {
+				return err
+			}
+		}
+		return pins.verify(&state)
+	}
+
+	pinned := *httpClient
+	pinned.Transport = transport
+	return &pinned
+}
@@ -0,0 +1,188 @@
+package authclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// roundTripperFunc は関数をhttp.RoundTripperとして扱います
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestCheckBaseURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		baseURL      string
+		strict       bool
+		wantInsecure bool
+		wantErr      error
+	}{
+		{name: "https", baseURL: "https://worker.example.com", strict: true},
+		{name: "http localhost", baseURL: "http://localhost:8787", strict: true},
+		{name: "http loopback ipv4", baseURL: "http://127.0.0.1:8787", strict: true},
+		{name: "http loopback ipv6", baseURL: "http://[::1]:8787", strict: true},
+		{name: "http remote strict", baseURL: "http://worker.example.com", strict: true, wantInsecure: true, wantErr: ErrInsecureTransport},
+		{name: "http remote lenient", baseURL: "http://worker.example.com", strict: false, wantInsecure: true},
+		{name: "localhost prefix trick", baseURL: "http://localhost.evil.example", strict: true, wantInsecure: true, wantErr: ErrInsecureTransport},
+		{name: "unsupported scheme", baseURL: "ftp://worker.example.com", wantErr: ErrInvalidConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insecure, err := checkBaseURL(tt.baseURL, tt.strict)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("checkBaseURL() error = %v, want %v", err, tt.wantErr)
+			}
+			if insecure != tt.wantInsecure {
+				t.Errorf("checkBaseURL() insecure = %v, want %v", insecure, tt.wantInsecure)
+			}
+		})
+	}
+}
+
+func TestPinnedSPKIHashes(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	goodPin := SPKIHash(server.Certificate())
+	otherSum := sha256.Sum256([]byte("other key"))
+	badPin := base64.StdEncoding.EncodeToString(otherSum[:])
+
+	// ハンドシェイク時の検証とレスポンス受信時の検証の両方を確認する
+	baseTransport := server.Client().Transport
+	customTransport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return baseTransport.RoundTrip(req)
+	})
+
+	tests := []struct {
+		name      string
+		pins      []string
+		transport http.RoundTripper
+		wantErr   error
+	}{
+		{name: "matching pin", pins: []string{badPin, goodPin}, transport: baseTransport},
+		{name: "mismatched pin", pins: []string{badPin}, transport: baseTransport, wantErr: ErrCertificatePinMismatch},
+		{name: "matching pin custom transport", pins: []string{goodPin}, transport: customTransport},
+		{name: "mismatched pin custom transport", pins: []string{badPin}, transport: customTransport, wantErr: ErrCertificatePinMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(ClientConfig{
+				BaseURL:          server.URL,
+				ClientID:         "test-client",
+				PrivateKey:       privateKey,
+				HTTPClient:       &http.Client{Transport: tt.transport},
+				StrictTransport:  true,
+				PinnedSPKIHashes: tt.pins,
+			})
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+
+			_, err = client.Health()
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Health() error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Health() error = %v, want %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrNetworkError) {
+				t.Errorf("Health() error = %v, pin mismatch must not be a retryable network error", err)
+			}
+		})
+	}
+
+	// 不正な形式のピンは設定エラー
+	_, err = NewClient(ClientConfig{
+		BaseURL:          server.URL,
+		ClientID:         "test-client",
+		PrivateKey:       privateKey,
+		PinnedSPKIHashes: []string{"not-a-hash"},
+	})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("NewClient() error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestPinnedSPKIHashes_VerifiedChainOnly(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	// 検証経路に含まれない証明書をチェーンに追加して送るサーバー
+	decoyKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "decoy"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	decoyDER, err := x509.CreateCertificate(rand.Reader, template, template, &decoyKey.PublicKey, decoyKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	decoy, err := x509.ParseCertificate(decoyDER)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	server.StartTLS()
+	defer server.Close()
+	server.TLS.Certificates[0].Certificate = append(server.TLS.Certificates[0].Certificate, decoyDER)
+
+	baseTransport := server.Client().Transport
+	customTransport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return baseTransport.RoundTrip(req)
+	})
+
+	for name, transport := range map[string]http.RoundTripper{"handshake": baseTransport, "response": customTransport} {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(ClientConfig{
+				BaseURL:          server.URL,
+				ClientID:         "test-client",
+				PrivateKey:       privateKey,
+				HTTPClient:       &http.Client{Transport: transport},
+				PinnedSPKIHashes: []string{SPKIHash(decoy)},
+			})
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+
+			if _, err := client.Health(); !errors.Is(err, ErrCertificatePinMismatch) {
+				t.Errorf("Health() error = %v, want ErrCertificatePinMismatch", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package authclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+)
+
+// defaultMaxResponseBytes はWorkerのレスポンスボディの最大サイズのデフォルト値
+const defaultMaxResponseBytes = 1 << 20
+
+// responseValidator は厳格モードで必須フィールドを検証するレスポンス型
+type responseValidator interface {
+	validate() error
+}
+
+// readResponseBody は最大サイズを超えない範囲でレスポンスボディを読み込みます
+func (c *Client) readResponseBody(path string, body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, c.maxResponseBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if int64(len(data)) > c.maxResponseBytes {
+		return nil, &ProtocolError{
+			Path:   path,
+			Reason: fmt.Sprintf("response body exceeds %d bytes", c.maxResponseBytes),
+		}
+	}
+	return data, nil
+}
+
+// checkContentType はレスポンスがJSONであることを確認します
+// Content-Typeがない場合は厳格モードでのみ拒否します
+func (c *Client) checkContentType(path, contentType string) error {
+	if contentType == "" {
+		if c.strictResponses {
+			return &ProtocolError{Path: path, Reason: "missing Content-Type"}
+		}
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "application/json" {
+		return &ProtocolError{Path: path, Reason: fmt.Sprintf("unexpected Content-Type %q", contentType)}
+	}
+	return nil
+}
+
+// decodeResponse はレスポンスボディをvにデコードします
+// 厳格モードでは未知のフィールド・末尾の余分なデータ・必須フィールドの欠落を拒否します
+func (c *Client) decodeResponse(path string, body []byte, v any) error {
+	if !c.strictResponses {
+		if err := json.Unmarshal(body, v); err != nil {
+			return &ProtocolError{Path: path, Reason: "invalid JSON", Err: err}
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return &ProtocolError{Path: path, Reason: "invalid JSON", Err: err}
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return &ProtocolError{Path: path, Reason: "unexpected data after JSON object"}
+	}
+
+	if validator, ok := v.(responseValidator); ok {
+		if err := validator.validate(); err != nil {
+			return &ProtocolError{Path: path, Reason: "missing required field", Err: err}
+		}
+	}
+	return nil
+}
+
+func (r *ChallengeResponse) validate() error {
+	if r.Challenge == "" {
+		return errors.New("challenge is empty")
+	}
+	if r.ExpiresAt <= 0 {
+		return errors.New("expiresAt is missing")
+	}
+	return nil
+}
+
+func (r *VerifyResponse) validate() error {
+	if r.Success && r.AccessToken == "" {
+		return errors.New("accessToken is empty")
+	}
+	return nil
+}
+
+func (r *HealthResponse) validate() error {
+	if r.Status == "" {
+		return errors.New("status is empty")
+	}
+	return nil
+}
+
+func (r *TunnelRegisterResponse) validate() error {
+	if r.Success {
+		return r.Data.validate()
+	}
+	return nil
+}
+
+func (r *TunnelGetResponse) validate() error {
+	if r.Success {
+		return r.Data.validate()
+	}
+	return nil
+}
+
+func (r *TunnelListResponse) validate() error {
+	if !r.Success {
+		return nil
+	}
+	for i := range r.Data {
+		if err := r.Data[i].validate(); err != nil {
+			return fmt.Errorf("data[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (r *IntrospectResponse) validate() error {
+	if r.Success && r.Active && r.ClientID == "" {
+		return errors.New("clientId is empty")
+	}
+	return nil
+}
+
+func (d *TunnelData) validate() error {
+	if d.ClientID == "" {
+		return errors.New("data.clientId is empty")
+	}
+	if d.TunnelUrl == "" {
+		return errors.New("data.tunnelUrl is empty")
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package authclient
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestChallenge_ResponseValidation(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		strict      bool
+		wantReason  string // 空の場合は成功を期待
+	}{
+		{
+			name:        "valid",
+			contentType: "application/json",
+			body:        `{"challenge":"abc","expiresAt":1893456000}`,
+			strict:      true,
+		},
+		{
+			name:        "json with charset",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"challenge":"abc","expiresAt":1893456000}`,
+			strict:      true,
+		},
+		{
+			name:        "too large",
+			contentType: "application/json",
+			body:        `{"challenge":"` + strings.Repeat("a", 200) + `","expiresAt":1893456000}`,
+			wantReason:  "response body exceeds 128 bytes",
+		},
+		{
+			name:        "html content type",
+			contentType: "text/html",
+			body:        `{"challenge":"abc","expiresAt":1893456000}`,
+			wantReason:  `unexpected Content-Type "text/html"`,
+		},
+		{
+			name:   "missing content type lenient",
+			body:   `{"challenge":"abc","expiresAt":1893456000}`,
+			strict: false,
+		},
+		{
+			name:       "missing content type strict",
+			body:       `{"challenge":"abc","expiresAt":1893456000}`,
+			strict:     true,
+			wantReason: "missing Content-Type",
+		},
+		{
+			name:        "invalid json",
+			contentType: "application/json",
+			body:        `<html>`,
+			wantReason:  "invalid JSON",
+		},
+		{
+			name:        "unknown field lenient",
+			contentType: "application/json",
+			body:        `{"challenge":"abc","expiresAt":1893456000,"extra":true}`,
+		},
+		{
+			name:        "unknown field strict",
+			contentType: "application/json",
+			body:        `{"challenge":"abc","expiresAt":1893456000,"extra":true}`,
+			strict:      true,
+			wantReason:  "invalid JSON",
+		},
+		{
+			name:        "trailing data strict",
+			contentType: "application/json",
+			body:        `{"challenge":"abc","expiresAt":1893456000}{}`,
+			strict:      true,
+			wantReason:  "unexpected data after JSON object",
+		},
+		{
+			name:        "empty challenge strict",
+			contentType: "application/json",
+			body:        `{"challenge":"","expiresAt":1893456000}`,
+			strict:      true,
+			wantReason:  "missing required field",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Content-Typeの自動判定を防ぐため常に明示的に設定
+				w.Header()["Content-Type"] = nil
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client, err := NewClient(ClientConfig{
+				BaseURL:          server.URL,
+				ClientID:         "test-client",
+				PrivateKey:       privateKey,
+				MaxResponseBytes: 128,
+				StrictResponses:  tt.strict,
+			})
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+
+			_, err = client.RequestChallenge()
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Fatalf("RequestChallenge() error = %v", err)
+				}
+				return
+			}
+
+			var protoErr *ProtocolError
+			if !errors.As(err, &protoErr) || !errors.Is(err, ErrProtocol) {
+				t.Fatalf("RequestChallenge() error = %v, want *ProtocolError", err)
+			}
+			if protoErr.Reason != tt.wantReason || protoErr.Path != "/challenge" {
+				t.Errorf("ProtocolError = {Path: %q, Reason: %q}, want {/challenge, %q}",
+					protoErr.Path, protoErr.Reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestDecodeResponse_StrictRequiredFields(t *testing.T) {
+	client := &Client{strictResponses: true}
+
+	tests := []struct {
+		name    string
+		body    string
+		v       any
+		wantErr bool
+	}{
+		{name: "verify success", body: `{"success":true,"token":"t","accessToken":"a","secretData":{}}`, v: &VerifyResponse{}},
+		{name: "verify missing accessToken", body: `{"success":true,"token":"t","secretData":{}}`, v: &VerifyResponse{}, wantErr: true},
+		{name: "verify failure", body: `{"success":false,"error":"denied"}`, v: &VerifyResponse{}},
+		{name: "health", body: `{"status":"ok"}`, v: &HealthResponse{}},
+		{name: "health missing status", body: `{}`, v: &HealthResponse{}, wantErr: true},
+		{name: "tunnel", body: `{"success":true,"data":{"clientId":"c","tunnelUrl":"https://t.example.com"}}`, v: &TunnelGetResponse{}},
+		{name: "tunnel missing url", body: `{"success":true,"data":{"clientId":"c"}}`, v: &TunnelGetResponse{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.decodeResponse("/test", []byte(tt.body), tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrProtocol) {
+				t.Errorf("error = %v, want ErrProtocol", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMaxRetryAfter はRetry-Afterで指定された待機時間のデフォルトの上限
+const defaultMaxRetryAfter = time.Minute
+
+// RetryPolicy は認証失敗時にリトライするかどうかと待機時間を決定します
+type RetryPolicy interface {
+	// NextDelay はattempt回目（1始まり）の試行がerrで失敗した後に呼ばれます
+	// elapsedは最初の試行開始からの経過時間です
+	// 次の試行までの待機時間と、リトライするかどうかを返します
+	NextDelay(attempt int, elapsed time.Duration, err error) (time.Duration, bool)
+}
+
+// ExponentialBackoff は指数バックオフとジッターによるRetryPolicy
+// エラーがRetry-Afterを含む場合はその時間以上待機します（MaxRetryAfterが上限）
+type ExponentialBackoff struct {
+	// MaxRetries は最大リトライ回数（0の場合はリトライしない）
+	MaxRetries int
+
+	// InitialInterval は最初のリトライまでの待機時間（デフォルト: 500ミリ秒）
+	InitialInterval time.Duration
+
+	// MaxInterval は待機時間の上限（デフォルト: 30秒、ジッター適用後も超えません）
+	// Retry-Afterで指定された待機時間にはMaxRetryAfterが適用されます
+	MaxInterval time.Duration
+
+	// MaxRetryAfter はRetry-Afterで指定された待機時間の上限（デフォルト: 1分）
+	// サーバーがこれより長い待機時間を指定した場合も、この時間でリトライします
+	MaxRetryAfter time.Duration
+
+	// Multiplier はリトライごとの待機時間の倍率（デフォルト: 2、1で固定間隔）
+	Multiplier float64
+
+	// Jitter は待機時間に加えるランダムな揺らぎの割合（0〜1、0でジッターなし）
+	Jitter float64
+
+	// MaxElapsedTime はリトライを含む全体の経過時間の上限（0の場合は無制限）
+	MaxElapsedTime time.Duration
+
+	// Retryable はエラーがリトライ可能か判定する関数（nilの場合はIsRetryable）
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy はデフォルトの指数バックオフ + ジッターのポリシーを返します
+func DefaultRetryPolicy(maxRetries int) *ExponentialBackoff {
+	return &ExponentialBackoff{
+		MaxRetries:      maxRetries,
+		InitialInterval: 500 * time.Millisecond,
+		MaxInterval:     30 * time.Second,
+		Multiplier:      2,
+		Jitter:          0.5,
+		MaxElapsedTime:  2 * time.Minute,
+	}
+}
+
+// NextDelay はRetryPolicyの実装です
+func (p *ExponentialBackoff) NextDelay(attempt int, elapsed time.Duration, err error) (time.Duration, bool) {
+	if attempt > p.MaxRetries {
+		return 0, false
+	}
+
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	if !retryable(err) {
+		return 0, false
+	}
+
+	initial := p.InitialInterval
+	if initial <= 0 {
+		initial = 500 * time.Millisecond
+	}
+	maxInterval := p.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = 30 * time.Second
+	}
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	maxRetryAfter := p.MaxRetryAfter
+	if maxRetryAfter <= 0 {
+		maxRetryAfter = defaultMaxRetryAfter
+	}
+
+	// initial * multiplier^(attempt-1) にジッターを加え、上限付きで計算
+	delay := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		delay = delay * (1 - jitter + 2*jitter*rand.Float64())
+	}
+	if delay > float64(maxInterval) {
+		delay = float64(maxInterval)
+	}
+
+	wait := time.Duration(delay)
+
+	// サーバーが指定したRetry-Afterを上限付きで優先
+	if retryAfter := retryAfterOf(err, maxRetryAfter); retryAfter > wait {
+		wait = retryAfter
+	}
+
+	if p.MaxElapsedTime > 0 && elapsed+wait > p.MaxElapsedTime {
+		return 0, false
+	}
+
+	return wait, true
+}
+
+// fixedBackoff はSetRetryで設定する固定間隔のRetryPolicy
+// intervalが0の場合は待機せずにリトライします（Retry-Afterがあればその時間待機、上限は1分）
+type fixedBackoff struct {
+	maxRetries int
+	interval   time.Duration
+}
+
+// NextDelay はRetryPolicyの実装です
+func (p *fixedBackoff) NextDelay(attempt int, elapsed time.Duration, err error) (time.Duration, bool) {
+	if attempt > p.maxRetries || !IsRetryable(err) {
+		return 0, false
+	}
+
+	wait := p.interval
+	if retryAfter := retryAfterOf(err, defaultMaxRetryAfter); retryAfter > wait {
+		wait = retryAfter
+	}
+	return wait, true
+}
+
+// retryAfterOf はerrが含むRetry-Afterの待機時間をlimitで切り詰めて返します
+// Retry-Afterがない場合は0を返します
+func retryAfterOf(err error, limit time.Duration) time.Duration {
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return 0
+	}
+	return min(httpErr.RetryAfter, limit)
+}
+
+// IsRetryable はエラーがリトライ可能かどうかを判定します
+// ネットワークエラー、5xx、429をリトライ可能とみなします
+func IsRetryable(err error) bool {
+	// キャンセル・期限切れはリトライしない
+	if isContextError(err) {
+		return false
+	}
+
+	// ネットワークエラーはリトライ可能
+	if errors.Is(err, ErrNetworkError) {
+		return true
+	}
+
+	// HTTPエラーの場合、ステータスコードで判定
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		// 5xxエラーはリトライ可能
+		if httpErr.StatusCode >= 500 && httpErr.StatusCode < 600 {
+			return true
+		}
+		// 429 Too Many Requestsもリトライ可能
+		if httpErr.StatusCode == http.StatusTooManyRequests {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseRetryAfter はRetry-Afterヘッダー（秒数またはHTTP日付）を待機時間に変換します
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// sleepContext は指定時間待機します。ctxがキャンセルされた場合はctxのエラーを返します
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,226 @@
+package authclient
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff_NextDelay(t *testing.T) {
+	policy := &ExponentialBackoff{
+		MaxRetries:      3,
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     250 * time.Millisecond,
+		Multiplier:      2,
+	}
+	serverErr := NewHTTPError(http.StatusServiceUnavailable, "unavailable", nil)
+
+	tests := []struct {
+		name      string
+		attempt   int
+		elapsed   time.Duration
+		err       error
+		wantDelay time.Duration
+		wantRetry bool
+	}{
+		{name: "first retry", attempt: 1, err: serverErr, wantDelay: 100 * time.Millisecond, wantRetry: true},
+		{name: "second retry doubles", attempt: 2, err: serverErr, wantDelay: 200 * time.Millisecond, wantRetry: true},
+		{name: "capped by max interval", attempt: 3, err: serverErr, wantDelay: 250 * time.Millisecond, wantRetry: true},
+		{name: "max retries exceeded", attempt: 4, err: serverErr, wantRetry: false},
+		{name: "not retryable", attempt: 1, err: ErrUnauthorized, wantRetry: false},
+		{name: "network error", attempt: 1, err: fmt.Errorf("%w: refused", ErrNetworkError), wantDelay: 100 * time.Millisecond, wantRetry: true},
+		{
+			name:      "retry-after honoured",
+			attempt:   1,
+			err:       &HTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: 3 * time.Second},
+			wantDelay: 3 * time.Second,
+			wantRetry: true,
+		},
+		{
+			name:      "retry-after capped",
+			attempt:   1,
+			err:       &HTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: 2 * time.Hour},
+			wantDelay: time.Minute,
+			wantRetry: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, retry := policy.NextDelay(tt.attempt, tt.elapsed, tt.err)
+			if retry != tt.wantRetry {
+				t.Fatalf("NextDelay() retry = %v, want %v", retry, tt.wantRetry)
+			}
+			if retry && delay != tt.wantDelay {
+				t.Errorf("NextDelay() delay = %v, want %v", delay, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestExponentialBackoff_MaxElapsedTime(t *testing.T) {
+	policy := &ExponentialBackoff{
+		MaxRetries:      10,
+		InitialInterval: time.Second,
+		MaxElapsedTime:  5 * time.Second,
+	}
+	err := NewHTTPError(http.StatusBadGateway, "bad gateway", nil)
+
+	if _, retry := policy.NextDelay(1, 3*time.Second, err); !retry {
+		t.Error("NextDelay() retry = false within elapsed budget, want true")
+	}
+	if _, retry := policy.NextDelay(2, 4500*time.Millisecond, err); retry {
+		t.Error("NextDelay() retry = true beyond elapsed budget, want false")
+	}
+}
+
+func TestExponentialBackoff_Jitter(t *testing.T) {
+	policy := &ExponentialBackoff{
+		MaxRetries:      1,
+		InitialInterval: time.Second,
+		Jitter:          0.5,
+	}
+	err := NewHTTPError(http.StatusInternalServerError, "error", nil)
+
+	for i := 0; i < 100; i++ {
+		delay, _ := policy.NextDelay(1, 0, err)
+		if delay < 500*time.Millisecond || delay > 1500*time.Millisecond {
+			t.Fatalf("NextDelay() delay = %v, want within [500ms, 1500ms]", delay)
+		}
+	}
+}
+
+func TestExponentialBackoff_JitterCappedByMaxInterval(t *testing.T) {
+	policy := &ExponentialBackoff{
+		MaxRetries:      10,
+		InitialInterval: time.Second,
+		MaxInterval:     4 * time.Second,
+		Jitter:          0.5,
+	}
+	err := NewHTTPError(http.StatusInternalServerError, "error", nil)
+
+	for i := 0; i < 100; i++ {
+		delay, _ := policy.NextDelay(5, 0, err)
+		if delay > policy.MaxInterval {
+			t.Fatalf("NextDelay() delay = %v, want at most %v", delay, policy.MaxInterval)
+		}
+	}
+}
+
+func TestExponentialBackoff_MaxRetryAfter(t *testing.T) {
+	policy := &ExponentialBackoff{
+		MaxRetries:    1,
+		MaxRetryAfter: 10 * time.Second,
+	}
+	err := &HTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}
+
+	if delay, ok := policy.NextDelay(1, 0, err); !ok || delay != 10*time.Second {
+		t.Errorf("NextDelay() = %v, %v, want 10s, true", delay, ok)
+	}
+}
+
+func TestSetRetry_ZeroBackoff(t *testing.T) {
+	client := &Client{}
+	client.SetRetry(2, 0)
+
+	unavailable := &HTTPError{StatusCode: http.StatusServiceUnavailable}
+	for attempt := 1; attempt <= 2; attempt++ {
+		if delay, ok := client.retryPolicy.NextDelay(attempt, 0, unavailable); !ok || delay != 0 {
+			t.Errorf("NextDelay(%d) = %v, %v, want 0, true", attempt, delay, ok)
+		}
+	}
+	if _, ok := client.retryPolicy.NextDelay(3, 0, unavailable); ok {
+		t.Error("NextDelay(3) should stop retrying")
+	}
+
+	// Retry-Afterは待機間隔が0でも守る
+	limited := &HTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Second}
+	if delay, ok := client.retryPolicy.NextDelay(1, 0, limited); !ok || delay != time.Second {
+		t.Errorf("NextDelay() with Retry-After = %v, %v, want 1s, true", delay, ok)
+	}
+
+	// 長すぎるRetry-Afterは上限で切り詰める
+	parked := &HTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: 6 * time.Hour}
+	if delay, ok := client.retryPolicy.NextDelay(1, 0, parked); !ok || delay != time.Minute {
+		t.Errorf("NextDelay() with long Retry-After = %v, %v, want 1m, true", delay, ok)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("2"); got != 2*time.Second {
+		t.Errorf("parseRetryAfter(\"2\") = %v, want 2s", got)
+	}
+	if got := parseRetryAfter(""); got != 0 {
+		t.Errorf("parseRetryAfter(\"\") = %v, want 0", got)
+	}
+	date := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got <= 0 || got > 10*time.Second {
+		t.Errorf("parseRetryAfter(%q) = %v, want within (0, 10s]", date, got)
+	}
+}
+
+func TestAuthenticate_RetryPolicy(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	// 最初の3回は429を返し、その後成功するサーバー
+	var calls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/challenge", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) <= 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "rate limited"})
+			return
+		}
+		json.NewEncoder(w).Encode(ChallengeResponse{
+			Challenge: "test-challenge",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		})
+	})
+	mux.HandleFunc("/verify", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(VerifyResponse{Success: true, AccessToken: "token"})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:    server.URL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+		RetryPolicy: &ExponentialBackoff{
+			MaxRetries:      3,
+			InitialInterval: time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if got := calls.Load(); got != 4 {
+		t.Errorf("/challenge called %d times, want 4", got)
+	}
+
+	// リトライ上限を超えると最後のエラーを返す
+	calls.Store(0)
+	client.SetRetry(1, time.Millisecond)
+	_, err = client.Authenticate()
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Authenticate() error = %v, want HTTP 429", err)
+	}
+}
@@ -0,0 +1,249 @@
+package authclient
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// secretCacheVersion はキャッシュファイルの形式のバージョン
+const secretCacheVersion = 1
+
+// secretCacheInfo はHKDFで暗号鍵を導出する際のinfo
+const secretCacheInfo = "go_auth secret cache v1"
+
+// defaultMaxStaleness はキャッシュを使用できるデフォルトの最大経過時間
+const defaultMaxStaleness = 24 * time.Hour
+
+// SecretCacheConfig はSecret変数のオフラインキャッシュの設定
+type SecretCacheConfig struct {
+	// Path はキャッシュファイルのパス（0600で保存されます）
+	Path string
+
+	// MaxStaleness はWorkerに到達できない場合に使用できるキャッシュの最大経過時間（デフォルト: 24時間）
+	MaxStaleness time.Duration
+
+	// Key は32バイトの暗号鍵（オプション）
+	// 未指定の場合はクライアントの秘密鍵から導出します
+	// 秘密鍵を取り出せず決定的な署名もできない署名器（ECDSAのHSMなど）では必須です
+	Key []byte
+}
+
+// secretCacheFile はキャッシュファイルの形式
+type secretCacheFile struct {
+	Version    int    `json:"version"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// secretCacheEntry は暗号化される内容
+type secretCacheEntry struct {
+	ClientID   string            `json:"clientId"`
+	FetchedAt  time.Time         `json:"fetchedAt"`
+	SecretData map[string]string `json:"secretData"`
+	RepoList   []string          `json:"repoList,omitempty"`
+}
+
+// secretCache は暗号化されたSecret変数のキャッシュ
+type secretCache struct {
+	path         string
+	maxStaleness time.Duration
+	ikm          []byte
+	clientID     string
+}
+
+// newSecretCache はキャッシュを作成します
+func newSecretCache(config *SecretCacheConfig, signer crypto.Signer, clientID string) (*secretCache, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("%w: secret cache path is required", ErrInvalidConfig)
+	}
+
+	ikm := config.Key
+	if ikm == nil {
+		derived, err := secretCacheKeyMaterial(signer)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+		ikm = derived
+	} else if len(ikm) != 32 {
+		return nil, fmt.Errorf("%w: secret cache key must be 32 bytes", ErrInvalidConfig)
+	}
+
+	maxStaleness := config.MaxStaleness
+	if maxStaleness <= 0 {
+		maxStaleness = defaultMaxStaleness
+	}
+
+	return &secretCache{
+		path:         config.Path,
+		maxStaleness: maxStaleness,
+		ikm:          ikm,
+		clientID:     clientID,
+	}, nil
+}
+
+// secretCacheKeyMaterial は秘密鍵から暗号鍵の元となる値を取得します
+// 秘密鍵を取り出せる場合はPKCS#8表現を、取り出せない場合は決定的な署名を使用します
+func secretCacheKeyMaterial(signer crypto.Signer) ([]byte, error) {
+	if der, err := x509.MarshalPKCS8PrivateKey(signer); err == nil {
+		return der, nil
+	}
+
+	// RSASSA-PKCS1-v1_5とEd25519の署名は決定的なので、外部の署名器でも同じ値が得られる
+	label := sha256.Sum256([]byte(secretCacheInfo))
+	switch signer.Public().(type) {
+	case *rsa.PublicKey:
+		return signer.Sign(rand.Reader, label[:], crypto.SHA256)
+	case ed25519.PublicKey:
+		return signer.Sign(rand.Reader, []byte(secretCacheInfo), crypto.Hash(0))
+	default:
+		return nil, errors.New("cannot derive secret cache key from signer, set SecretCacheConfig.Key")
+	}
+}
+
+// aead はソルトから導出した鍵でAES-GCMを作成します
+func (c *secretCache) aead(salt []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, c.ikm, salt, secretCacheInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// save は認証レスポンスのSecret変数を暗号化して保存します
+func (c *secretCache) save(resp *VerifyResponse, fetchedAt time.Time) error {
+	plaintext, err := json.Marshal(secretCacheEntry{
+		ClientID:   c.clientID,
+		FetchedAt:  fetchedAt,
+		SecretData: resp.SecretData,
+		RepoList:   resp.RepoList,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal secret cache: %w", err)
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	aead, err := c.aead(salt)
+	if err != nil {
+		return fmt.Errorf("failed to initialize cipher: %w", err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	data, err := json.Marshal(secretCacheFile{
+		Version:    secretCacheVersion,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, []byte(c.clientID)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal secret cache: %w", err)
+	}
+
+	// 一時ファイルに書き込んでからリネームし、途中で壊れたキャッシュを残さない
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create secret cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set secret cache permissions: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write secret cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write secret cache file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to write secret cache file: %w", err)
+	}
+	return nil
+}
+
+// load はキャッシュを復号し、最大経過時間以内であれば古いことを示すフラグ付きで返します
+func (c *secretCache) load(now time.Time) (*VerifyResponse, error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret cache file: %w", err)
+	}
+
+	var file secretCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse secret cache file: %w", err)
+	}
+	if file.Version != secretCacheVersion {
+		return nil, fmt.Errorf("unsupported secret cache version %d", file.Version)
+	}
+
+	aead, err := c.aead(file.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cipher: %w", err)
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid secret cache nonce")
+	}
+
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, []byte(c.clientID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret cache: %w", err)
+	}
+
+	var entry secretCacheEntry
+	if err := json.Unmarshal(plaintext, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse secret cache: %w", err)
+	}
+
+	if age := now.Sub(entry.FetchedAt); age > c.maxStaleness {
+		return nil, fmt.Errorf("secret cache is too old: fetched %s ago", age.Round(time.Second))
+	}
+
+	return &VerifyResponse{
+		Success:    true,
+		SecretData: entry.SecretData,
+		RepoList:   entry.RepoList,
+		Stale:      true,
+		FetchedAt:  entry.FetchedAt,
+	}, nil
+}
+
+// isWorkerUnreachable はWorkerに到達できないことを示すエラーか判定します
+// ネットワークエラーと5xxが該当し、認証拒否などはキャッシュで代替しません
+func isWorkerUnreachable(err error) bool {
+	if errors.Is(err, ErrNetworkError) {
+		return true
+	}
+
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode >= http.StatusInternalServerError
+}
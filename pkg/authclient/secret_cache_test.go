@@ -0,0 +1,166 @@
+package authclient
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSecretCache_OfflineFallback(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var down atomic.Bool
+	server := setupTestServer(t, privateKey)
+	defer server.Close()
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		server.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	cachePath := filepath.Join(t.TempDir(), "secrets.cache")
+	client, err := NewClient(ClientConfig{
+		BaseURL:     proxy.URL,
+		ClientID:    "test-client",
+		PrivateKey:  privateKey,
+		SecretCache: &SecretCacheConfig{Path: cachePath, MaxStaleness: time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	fresh, err := client.Authenticate()
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if fresh.Stale {
+		t.Error("Authenticate() Stale = true for a live response")
+	}
+
+	// キャッシュは暗号化され0600で保存される
+	info, err := os.Stat(cachePath)
+	if err != nil {
+		t.Fatalf("cache file not written: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("cache file permission = %o, want 600", perm)
+	}
+	data, _ := os.ReadFile(cachePath)
+	if strings.Contains(string(data), "test-secret") {
+		t.Error("cache file contains plaintext secret")
+	}
+
+	// Workerがダウンしてもキャッシュから返る
+	down.Store(true)
+	stale, err := client.Authenticate()
+	if err != nil {
+		t.Fatalf("Authenticate() while offline error = %v", err)
+	}
+	if !stale.Stale {
+		t.Error("Authenticate() Stale = false for a cached response")
+	}
+	if stale.SecretData["SECRET_DATA"] != "test-secret" {
+		t.Errorf("cached SecretData = %v", stale.SecretData)
+	}
+	if stale.FetchedAt.IsZero() {
+		t.Error("cached FetchedAt is zero")
+	}
+
+	// 別の鍵ではキャッシュを復号できない
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	other, err := NewClient(ClientConfig{
+		BaseURL:     proxy.URL,
+		ClientID:    "test-client",
+		PrivateKey:  otherKey,
+		SecretCache: &SecretCacheConfig{Path: cachePath},
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if _, err := other.Authenticate(); err == nil {
+		t.Error("Authenticate() with a different key succeeded from cache")
+	}
+}
+
+func TestSecretCache_MaxStaleness(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	cache, err := newSecretCache(&SecretCacheConfig{
+		Path:         filepath.Join(t.TempDir(), "secrets.cache"),
+		MaxStaleness: time.Hour,
+	}, privateKey, "test-client")
+	if err != nil {
+		t.Fatalf("newSecretCache() error = %v", err)
+	}
+
+	fetchedAt := time.Now()
+	if err := cache.save(&VerifyResponse{SecretData: map[string]string{"KEY": "value"}}, fetchedAt); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+
+	if _, err := cache.load(fetchedAt.Add(30 * time.Minute)); err != nil {
+		t.Errorf("load() within MaxStaleness error = %v", err)
+	}
+	if _, err := cache.load(fetchedAt.Add(2 * time.Hour)); err == nil {
+		t.Error("load() beyond MaxStaleness error = nil, want error")
+	}
+}
+
+func TestSecretCache_NotUsedForAuthFailure(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	cachePath := filepath.Join(t.TempDir(), "secrets.cache")
+	cache, err := newSecretCache(&SecretCacheConfig{Path: cachePath}, privateKey, "test-client")
+	if err != nil {
+		t.Fatalf("newSecretCache() error = %v", err)
+	}
+	if err := cache.save(&VerifyResponse{SecretData: map[string]string{"KEY": "value"}}, time.Now()); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+
+	// 認証拒否（401）ではキャッシュを使わない
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Unknown client"})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:     server.URL,
+		ClientID:    "test-client",
+		PrivateKey:  privateKey,
+		SecretCache: &SecretCacheConfig{Path: cachePath},
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err := client.Authenticate(); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Authenticate() error = %v, want ErrUnauthorized", err)
+	}
+}
@@ -0,0 +1,298 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SecretEventType はSecret変数の変更の種類
+type SecretEventType string
+
+const (
+	// SecretAdded は新しいキーが追加された
+	SecretAdded SecretEventType = "added"
+
+	// SecretChanged は既存のキーの値が変更された
+	SecretChanged SecretEventType = "changed"
+
+	// SecretRemoved はキーが削除された
+	SecretRemoved SecretEventType = "removed"
+)
+
+// SecretEvent は1つのキーの変更
+type SecretEvent struct {
+	// Type は変更の種類
+	Type SecretEventType
+
+	// Key はSecret変数のキー
+	Key string
+
+	// OldValue は変更前の値（追加の場合は空）
+	OldValue string
+
+	// NewValue は変更後の値（削除の場合は空）
+	NewValue string
+}
+
+// SecretChange は1回のポーリングで検出された変更のまとまり
+type SecretChange struct {
+	// Events はキー順に並んだ変更の一覧
+	Events []SecretEvent
+
+	// Secrets は変更後の全てのSecret変数
+	Secrets map[string]string
+}
+
+// SecretWatcherConfig はSecretWatcherの設定
+type SecretWatcherConfig struct {
+	// Interval はWorkerに再認証して変更を確認する間隔（デフォルト: 5分）
+	Interval time.Duration
+}
+
+// SecretWatcher は定期的に再認証してSecret変数の変更を購読者に通知します
+type SecretWatcher struct {
+	client   *Client
+	interval time.Duration
+
+	pollMu sync.Mutex // Pollを直列化する
+
+	mu          sync.Mutex
+	snapshot    map[string]string
+	primed      bool
+	subscribers map[*secretSubscriber]struct{}
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+// secretSubscriber は1つの購読
+type secretSubscriber struct {
+	ch   chan SecretChange
+	stop chan struct{}
+	once sync.Once
+
+	// delivered はこの購読者に最後に通知した時点のSecret変数（pollMuで保護）
+	// バッファが埋まっていて通知できなかった変更は、次のポーリングでこのスナップショットとの差分として通知されます
+	delivered map[string]string
+	primed    bool
+}
+
+// NewSecretWatcher は新しいSecretWatcherを作成します
+func NewSecretWatcher(client *Client, config SecretWatcherConfig) *SecretWatcher {
+	interval := config.Interval
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	return &SecretWatcher{
+		client:      client,
+		interval:    interval,
+		subscribers: make(map[*secretSubscriber]struct{}),
+	}
+}
+
+// Subscribe は変更通知を受け取るチャネルと購読解除の関数を返します
+// 購読開始時点のスナップショットからの変更を通知します
+// 通知はブロックせず、バッファ（1未満の場合は1）が埋まっている購読者には送信しません
+// 送信できなかった変更は、受信後のポーリングで最後に通知した時点からの差分としてまとめて通知されます
+func (w *SecretWatcher) Subscribe(buffer int) (<-chan SecretChange, func()) {
+	if buffer < 1 {
+		buffer = 1
+	}
+
+	sub := &secretSubscriber{
+		ch:   make(chan SecretChange, buffer),
+		stop: make(chan struct{}),
+	}
+
+	w.mu.Lock()
+	if w.primed {
+		sub.delivered = copySecrets(w.snapshot)
+		sub.primed = true
+	}
+	w.subscribers[sub] = struct{}{}
+	w.mu.Unlock()
+
+	unsubscribe := func() {
+		sub.once.Do(func() {
+			w.mu.Lock()
+			delete(w.subscribers, sub)
+			w.mu.Unlock()
+			close(sub.stop)
+		})
+	}
+	return sub.ch, unsubscribe
+}
+
+// Start は初回の取得を同期的に実行して基準となるスナップショットを作成し、
+// バックグラウンドでのポーリングを開始します
+// 初回の取得に失敗した場合は開始せず、再びStartを呼び出せます
+func (w *SecretWatcher) Start(ctx context.Context) error {
+	loopCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	// 同時に呼ばれたStartが両方とも開始しないよう、初回の処理の前に開始済みにする
+	w.mu.Lock()
+	if w.done != nil {
+		w.mu.Unlock()
+		cancel()
+		return errors.New("secret watcher already started")
+	}
+	w.cancel = cancel
+	w.done = done
+	w.mu.Unlock()
+
+	if err := w.Poll(loopCtx); err != nil {
+		cancel()
+		w.mu.Lock()
+		w.cancel = nil
+		w.done = nil
+		w.mu.Unlock()
+		close(done)
+		return err
+	}
+
+	go w.run(loopCtx, done)
+	return nil
+}
+
+// Stop はポーリングを停止し、終了を待ちます
+func (w *SecretWatcher) Stop() {
+	w.mu.Lock()
+	cancel, done := w.cancel, w.done
+	w.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Snapshot は最後に取得したSecret変数の複製を返します
+func (w *SecretWatcher) Snapshot() map[string]string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return copySecrets(w.snapshot)
+}
+
+// Poll は即座に再認証して変更を確認し、変更があれば購読者に通知します
+// 初回はスナップショットを作成するだけで通知しません
+// オフラインキャッシュから返された古いSecret変数は比較に使用しません
+// 同時に呼び出された場合は1つずつ実行します
+func (w *SecretWatcher) Poll(ctx context.Context) error {
+	w.pollMu.Lock()
+	defer w.pollMu.Unlock()
+
+	resp, err := w.client.AuthenticateContext(ctx)
+	if err != nil {
+		return err
+	}
+	if resp.Stale {
+		return ErrStaleSecrets
+	}
+	current := copySecrets(resp.SecretData)
+
+	w.mu.Lock()
+	previous, primed := w.snapshot, w.primed
+	w.snapshot = current
+	w.primed = true
+	subscribers := make([]*secretSubscriber, 0, len(w.subscribers))
+	for sub := range w.subscribers {
+		subscribers = append(subscribers, sub)
+	}
+	w.mu.Unlock()
+
+	if primed {
+		if changes := diffSecrets(previous, current); len(changes) > 0 {
+			w.client.logger.Info("secrets changed", "client_id", w.client.clientID, "changes", len(changes))
+		}
+	}
+
+	// 購読者ごとに最後に通知したスナップショットとの差分を通知する
+	for _, sub := range subscribers {
+		if !sub.primed {
+			sub.delivered = current
+			sub.primed = true
+			continue
+		}
+
+		events := diffSecrets(sub.delivered, current)
+		if len(events) == 0 {
+			continue
+		}
+
+		select {
+		case <-sub.stop:
+			continue
+		default:
+		}
+
+		// 受信しない購読者が他の購読者やポーリングを止めないよう、ブロックせずに送信する
+		change := SecretChange{Events: events, Secrets: copySecrets(current)}
+		select {
+		case sub.ch <- change:
+			sub.delivered = current
+		default:
+			w.client.logger.Debug("secret change delivery deferred, subscriber buffer full",
+				"client_id", w.client.clientID, "changes", len(events))
+		}
+	}
+	return nil
+}
+
+// run は一定間隔でポーリングを繰り返します
+func (w *SecretWatcher) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := w.Poll(ctx); err != nil && ctx.Err() == nil {
+			w.client.logger.Warn("secret poll failed", "client_id", w.client.clientID, "error", err)
+		}
+	}
+}
+
+// diffSecrets は2つのスナップショットの差分をキー順に返します
+func diffSecrets(previous, current map[string]string) []SecretEvent {
+	var events []SecretEvent
+
+	for key, newValue := range current {
+		oldValue, ok := previous[key]
+		switch {
+		case !ok:
+			events = append(events, SecretEvent{Type: SecretAdded, Key: key, NewValue: newValue})
+		case oldValue != newValue:
+			events = append(events, SecretEvent{Type: SecretChanged, Key: key, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+	for key, oldValue := range previous {
+		if _, ok := current[key]; !ok {
+			events = append(events, SecretEvent{Type: SecretRemoved, Key: key, OldValue: oldValue})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Key < events[j].Key
+	})
+	return events
+}
+
+// copySecrets はSecret変数のマップを複製します
+func copySecrets(secrets map[string]string) map[string]string {
+	clone := make(map[string]string, len(secrets))
+	for key, value := range secrets {
+		clone[key] = value
+	}
+	return clone
+}
@@ -0,0 +1,328 @@
+package authclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setupSecretServer は secrets の現在値を SecretData として返すテストサーバーを作成します
+func setupSecretServer(t *testing.T, mu *sync.Mutex, secrets *map[string]string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/challenge", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ChallengeResponse{
+			Challenge: "test-challenge",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		})
+	})
+	mux.HandleFunc("/verify", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		data := copySecrets(*secrets)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(VerifyResponse{
+			Success:    true,
+			Token:      "tunnel-token",
+			SecretData: data,
+		})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func newSecretWatcherClient(t *testing.T, baseURL string) *Client {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:    baseURL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
+
+func TestSecretWatcher_PollEmitsDiff(t *testing.T) {
+	var mu sync.Mutex
+	secrets := map[string]string{"DB_USER": "app", "DB_PASSWORD": "old", "LEGACY": "x"}
+	server := setupSecretServer(t, &mu, &secrets)
+	defer server.Close()
+
+	watcher := NewSecretWatcher(newSecretWatcherClient(t, server.URL), SecretWatcherConfig{})
+	events, unsubscribe := watcher.Subscribe(1)
+	defer unsubscribe()
+
+	ctx := context.Background()
+	if err := watcher.Poll(ctx); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	select {
+	case change := <-events:
+		t.Fatalf("unexpected change on first poll: %+v", change)
+	default:
+	}
+
+	mu.Lock()
+	secrets = map[string]string{"DB_USER": "app", "DB_PASSWORD": "new", "API_KEY": "k"}
+	mu.Unlock()
+
+	if err := watcher.Poll(ctx); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+
+	var change SecretChange
+	select {
+	case change = <-events:
+	default:
+		t.Fatal("expected change event")
+	}
+
+	want := []SecretEvent{
+		{Type: SecretAdded, Key: "API_KEY", NewValue: "k"},
+		{Type: SecretChanged, Key: "DB_PASSWORD", OldValue: "old", NewValue: "new"},
+		{Type: SecretRemoved, Key: "LEGACY", OldValue: "x"},
+	}
+	if !reflect.DeepEqual(change.Events, want) {
+		t.Errorf("Events = %+v, want %+v", change.Events, want)
+	}
+	if change.Secrets["DB_PASSWORD"] != "new" {
+		t.Errorf("Secrets[DB_PASSWORD] = %q, want new", change.Secrets["DB_PASSWORD"])
+	}
+
+	if err := watcher.Poll(ctx); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	select {
+	case change := <-events:
+		t.Errorf("unexpected change without update: %+v", change)
+	default:
+	}
+}
+
+func TestSecretWatcher_StartPolls(t *testing.T) {
+	var mu sync.Mutex
+	secrets := map[string]string{"API_KEY": "v1"}
+	server := setupSecretServer(t, &mu, &secrets)
+	defer server.Close()
+
+	watcher := NewSecretWatcher(newSecretWatcherClient(t, server.URL), SecretWatcherConfig{
+		Interval: 20 * time.Millisecond,
+	})
+	events, unsubscribe := watcher.Subscribe(0)
+	defer unsubscribe()
+
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer watcher.Stop()
+
+	if got := watcher.Snapshot()["API_KEY"]; got != "v1" {
+		t.Fatalf("Snapshot()[API_KEY] = %q, want v1", got)
+	}
+
+	mu.Lock()
+	secrets = map[string]string{"API_KEY": "v2"}
+	mu.Unlock()
+
+	select {
+	case change := <-events:
+		if len(change.Events) != 1 || change.Events[0].Type != SecretChanged || change.Events[0].NewValue != "v2" {
+			t.Errorf("Events = %+v, want API_KEY changed to v2", change.Events)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change")
+	}
+}
+
+func TestSecretWatcher_UnsubscribeDoesNotBlock(t *testing.T) {
+	var mu sync.Mutex
+	secrets := map[string]string{"API_KEY": "v1"}
+	server := setupSecretServer(t, &mu, &secrets)
+	defer server.Close()
+
+	watcher := NewSecretWatcher(newSecretWatcherClient(t, server.URL), SecretWatcherConfig{})
+	_, unsubscribe := watcher.Subscribe(0)
+
+	ctx := context.Background()
+	if err := watcher.Poll(ctx); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	unsubscribe()
+	unsubscribe()
+
+	mu.Lock()
+	secrets = map[string]string{"API_KEY": "v2"}
+	mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() { done <- watcher.Poll(ctx) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Poll() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Poll() blocked on unsubscribed listener")
+	}
+}
+
+func TestSecretWatcher_SlowSubscriberDoesNotBlock(t *testing.T) {
+	var mu sync.Mutex
+	secrets := map[string]string{"DB_PASSWORD": "v1"}
+	server := setupSecretServer(t, &mu, &secrets)
+	defer server.Close()
+
+	watcher := NewSecretWatcher(newSecretWatcherClient(t, server.URL), SecretWatcherConfig{})
+	slow, unsubscribeSlow := watcher.Subscribe(0)
+	defer unsubscribeSlow()
+	fast, unsubscribeFast := watcher.Subscribe(1)
+	defer unsubscribeFast()
+
+	// 受信しない購読者がいてもcontext.Backgroundのポーリングは戻る
+	poll := func(value string) {
+		t.Helper()
+		mu.Lock()
+		secrets = map[string]string{"DB_PASSWORD": value}
+		mu.Unlock()
+
+		done := make(chan error, 1)
+		go func() { done <- watcher.Poll(context.Background()) }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Poll() error = %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Poll() blocked on a subscriber that is not receiving")
+		}
+	}
+	expect := func(ch <-chan SecretChange, oldValue, newValue string) {
+		t.Helper()
+		select {
+		case change := <-ch:
+			want := []SecretEvent{{Type: SecretChanged, Key: "DB_PASSWORD", OldValue: oldValue, NewValue: newValue}}
+			if !reflect.DeepEqual(change.Events, want) {
+				t.Errorf("Events = %+v, want %+v", change.Events, want)
+			}
+		default:
+			t.Fatalf("no change delivered, want %s -> %s", oldValue, newValue)
+		}
+	}
+
+	poll("v1")
+	poll("v2")
+	expect(fast, "v1", "v2")
+
+	// slowのバッファはv2の通知で埋まっているため、v3は送信されない
+	poll("v3")
+	expect(fast, "v2", "v3")
+	expect(slow, "v1", "v2")
+	select {
+	case change := <-slow:
+		t.Fatalf("unexpected change %+v", change.Events)
+	default:
+	}
+
+	// 受信後のポーリングで、最後に通知したv2からの差分をまとめて通知する
+	poll("v4")
+	expect(fast, "v3", "v4")
+	expect(slow, "v2", "v4")
+}
+
+func TestSecretWatcher_ConcurrentPolls(t *testing.T) {
+	var mu sync.Mutex
+	secrets := map[string]string{"VERSION": "1"}
+	server := setupSecretServer(t, &mu, &secrets)
+	defer server.Close()
+
+	watcher := NewSecretWatcher(newSecretWatcherClient(t, server.URL), SecretWatcherConfig{})
+	events, unsubscribe := watcher.Subscribe(10)
+	defer unsubscribe()
+
+	if err := watcher.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+
+	mu.Lock()
+	secrets = map[string]string{"VERSION": "2"}
+	mu.Unlock()
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := watcher.Poll(context.Background()); err != nil {
+				t.Errorf("Poll() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 同時に実行されても変更は1度だけ通知される
+	if n := len(events); n != 1 {
+		t.Errorf("received %d changes, want 1", n)
+	}
+}
+
+func TestSecretWatcher_ConcurrentStart(t *testing.T) {
+	var mu sync.Mutex
+	secrets := map[string]string{"API_KEY": "v1"}
+	inner := setupSecretServer(t, &mu, &secrets)
+	defer inner.Close()
+
+	// 初回の取得に時間がかかるWorker
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		inner.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	watcher := NewSecretWatcher(newSecretWatcherClient(t, server.URL), SecretWatcherConfig{})
+
+	// 初回の取得に失敗した場合は開始済みにならない
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := watcher.Start(canceled); err == nil {
+		t.Fatal("Start() with canceled context should fail")
+	}
+
+	// 初回の取得中に呼ばれたStartは開始しない
+	var started atomic.Int32
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if watcher.Start(context.Background()) == nil {
+				started.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	watcher.Stop()
+
+	if n := started.Load(); n != 1 {
+		t.Errorf("successful Start() calls = %d, want 1", n)
+	}
+}
@@ -0,0 +1,230 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// TokenManagerConfig はTokenManagerの設定
+type TokenManagerConfig struct {
+	// TokenTTL はアクセストークンの有効期間（デフォルト: 1時間）
+	// WorkerがaccessTokenExpiresAtを返した場合はそちらを優先します
+	TokenTTL time.Duration
+
+	// RefreshBefore は有効期限の何前に再認証するか（デフォルト: トークンの有効期間の20%）
+	// 有効期間は取得時から有効期限までの実際の長さで、それ以上の値を指定した場合は有効期間の半分になります
+	RefreshBefore time.Duration
+
+	// MinRefreshInterval は再認証の最短間隔（デフォルト: 1秒）
+	// 有効期間が極端に短いトークンを返された場合でも、これより頻繁には再認証しません
+	MinRefreshInterval time.Duration
+
+	// MinBackoff は再認証失敗時の最初の待機時間（デフォルト: 1秒）
+	MinBackoff time.Duration
+
+	// MaxBackoff は再認証失敗時の最大待機時間（デフォルト: 1分）
+	MaxBackoff time.Duration
+}
+
+// TokenManager はアクセストークンの有効期限を管理し、期限切れ前に自動で再認証します
+// 再認証に失敗している間は古いトークンを返し続けます
+type TokenManager struct {
+	client *Client
+	config TokenManagerConfig
+
+	mu        sync.RWMutex
+	token     string
+	issuedAt  time.Time
+	expiresAt time.Time
+	changed   chan struct{}
+	lastErr   error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewTokenManager は新しいTokenManagerを作成します
+func NewTokenManager(client *Client, config TokenManagerConfig) *TokenManager {
+	if config.TokenTTL <= 0 {
+		config.TokenTTL = time.Hour
+	}
+	if config.MinRefreshInterval <= 0 {
+		config.MinRefreshInterval = time.Second
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = time.Minute
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = config.MinBackoff
+	}
+
+	return &TokenManager{
+		client:  client,
+		config:  config,
+		changed: make(chan struct{}),
+	}
+}
+
+// Start は初回認証を同期的に実行し、バックグラウンドでの更新を開始します
+// 更新はctxがキャンセルされるかStopが呼ばれるまで続きます
+// 初回認証に失敗した場合は開始せず、再びStartを呼び出せます
+func (m *TokenManager) Start(ctx context.Context) error {
+	loopCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	// 同時に呼ばれたStartが両方とも開始しないよう、初回の処理の前に開始済みにする
+	m.mu.Lock()
+	if m.done != nil {
+		m.mu.Unlock()
+		cancel()
+		return errors.New("token manager already started")
+	}
+	m.cancel = cancel
+	m.done = done
+	m.mu.Unlock()
+
+	if err := m.Refresh(loopCtx); err != nil {
+		cancel()
+		m.mu.Lock()
+		m.cancel = nil
+		m.done = nil
+		m.mu.Unlock()
+		close(done)
+		return err
+	}
+
+	go m.run(loopCtx, done)
+	return nil
+}
+
+// Stop はバックグラウンドでの更新を停止し、終了を待ちます
+func (m *TokenManager) Stop() {
+	m.mu.RLock()
+	cancel, done := m.cancel, m.done
+	m.mu.RUnlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Token は現在のアクセストークンを返します
+func (m *TokenManager) Token() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.token
+}
+
+// ExpiresAt は現在のアクセストークンの有効期限を返します
+func (m *TokenManager) ExpiresAt() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.expiresAt
+}
+
+// LastError は直近の再認証で発生したエラーを返します（成功時はnil）
+func (m *TokenManager) LastError() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.lastErr
+}
+
+// Changed はトークンが次に変更されたときにcloseされるチャネルを返します
+// 変更を受け取った後は再度Changedを呼んで次の変更を待ってください
+func (m *TokenManager) Changed() <-chan struct{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.changed
+}
+
+// Refresh は即座に再認証してトークンを更新します
+func (m *TokenManager) Refresh(ctx context.Context) error {
+	err := m.client.RenewAccessToken(ctx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastErr = err
+	if err != nil {
+		return err
+	}
+
+	token := m.client.GetAccessToken()
+	m.issuedAt = time.Now()
+	m.expiresAt = m.client.GetAccessTokenExpiresAt()
+	if m.expiresAt.IsZero() {
+		m.expiresAt = m.issuedAt.Add(m.config.TokenTTL)
+	}
+
+	if token != m.token {
+		m.token = token
+		close(m.changed)
+		m.changed = make(chan struct{})
+	}
+
+	return nil
+}
+
+// run は有効期限前の再認証を繰り返します
+func (m *TokenManager) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	backoff := m.config.MinBackoff
+	for {
+		if err := sleepContext(ctx, m.nextRefreshDelay()); err != nil {
+			return
+		}
+
+		for {
+			err := m.Refresh(ctx)
+			if err == nil {
+				backoff = m.config.MinBackoff
+				break
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			m.client.logger.Warn("token refresh failed, keeping current token",
+				"client_id", m.client.clientID, "retry_in", backoff, "error", err)
+
+			// 失敗時は古いトークンを保持したままバックオフして再試行
+			if err := sleepContext(ctx, backoff); err != nil {
+				return
+			}
+			backoff *= 2
+			if backoff > m.config.MaxBackoff {
+				backoff = m.config.MaxBackoff
+			}
+		}
+	}
+}
+
+// nextRefreshDelay は次の再認証までの待機時間を計算します
+// 設定したTokenTTLではなく、取得時から有効期限までの実際の有効期間を基準にします
+func (m *TokenManager) nextRefreshDelay() time.Duration {
+	m.mu.RLock()
+	issuedAt, expiresAt := m.issuedAt, m.expiresAt
+	m.mu.RUnlock()
+
+	lifetime := expiresAt.Sub(issuedAt)
+	refreshBefore := m.config.RefreshBefore
+	switch {
+	case refreshBefore <= 0:
+		refreshBefore = lifetime / 5
+	case refreshBefore >= lifetime:
+		refreshBefore = lifetime / 2
+	}
+
+	delay := time.Until(expiresAt.Add(-refreshBefore))
+	if delay < m.config.MinRefreshInterval {
+		return m.config.MinRefreshInterval
+	}
+	return delay
+}
@@ -0,0 +1,243 @@
+package authclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/authtest"
+)
+
+// setupTokenServer は認証のたびに異なるアクセストークンを返すテストサーバーを作成します
+// failがtrueの間は/challengeが503を返します
+func setupTokenServer(t *testing.T, fail *atomic.Bool) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var issued atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/challenge", func(w http.ResponseWriter, r *http.Request) {
+		if fail != nil && fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ChallengeResponse{
+			Challenge: "test-challenge",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		})
+	})
+	mux.HandleFunc("/verify", func(w http.ResponseWriter, r *http.Request) {
+		n := issued.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(VerifyResponse{
+			Success:     true,
+			AccessToken: fmt.Sprintf("token-%d", n),
+		})
+	})
+
+	return httptest.NewServer(mux), &issued
+}
+
+func TestTokenManager_ProactiveRefresh(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	server, _ := setupTokenServer(t, nil)
+	defer server.Close()
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:    server.URL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	manager := NewTokenManager(client, TokenManagerConfig{
+		TokenTTL:           300 * time.Millisecond,
+		RefreshBefore:      200 * time.Millisecond,
+		MinRefreshInterval: 10 * time.Millisecond,
+	})
+	if err := manager.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer manager.Stop()
+
+	first := manager.Token()
+	if first != "token-1" {
+		t.Fatalf("Token() = %q, want token-1", first)
+	}
+
+	select {
+	case <-manager.Changed():
+	case <-time.After(5 * time.Second):
+		t.Fatal("token was not refreshed before expiry")
+	}
+
+	if got := manager.Token(); got == first {
+		t.Errorf("Token() = %q after refresh, want a new token", got)
+	}
+	if got := client.GetAccessToken(); got != manager.Token() {
+		t.Errorf("client.GetAccessToken() = %q, want %q", got, manager.Token())
+	}
+}
+
+func TestTokenManager_KeepsOldTokenOnFailure(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var fail atomic.Bool
+	server, issued := setupTokenServer(t, &fail)
+	defer server.Close()
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:    server.URL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	manager := NewTokenManager(client, TokenManagerConfig{
+		TokenTTL:           100 * time.Millisecond,
+		RefreshBefore:      90 * time.Millisecond,
+		MinRefreshInterval: 10 * time.Millisecond,
+		MinBackoff:         10 * time.Millisecond,
+		MaxBackoff:         20 * time.Millisecond,
+	})
+	if err := manager.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer manager.Stop()
+
+	fail.Store(true)
+	time.Sleep(200 * time.Millisecond)
+
+	if got := manager.Token(); got != "token-1" {
+		t.Errorf("Token() = %q during outage, want token-1", got)
+	}
+	if manager.LastError() == nil {
+		t.Error("LastError() = nil during outage, want error")
+	}
+
+	// 復旧後は新しいトークンが取得される
+	changed := manager.Changed()
+	fail.Store(false)
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("token was not refreshed after recovery")
+	}
+	if issued.Load() < 2 {
+		t.Errorf("issued tokens = %d, want >= 2", issued.Load())
+	}
+}
+
+func TestTokenManager_UsesServerLifetime(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	// WorkerのTTLがTokenManagerのTokenTTL（デフォルト1時間）より短い
+	server := authtest.NewServer(authtest.Config{TokenTTL: 5 * time.Minute})
+	defer server.Close()
+	server.AddClient("test-client", &privateKey.PublicKey, nil)
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:    server.URL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	manager := NewTokenManager(client, TokenManagerConfig{})
+	if err := manager.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer manager.Stop()
+
+	// 有効期間5分の80%（4分後）まで再認証しない
+	if delay := manager.nextRefreshDelay(); delay < 3*time.Minute || delay > 4*time.Minute {
+		t.Errorf("nextRefreshDelay() = %v, want about 4m", delay)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if n := server.Requests("/verify"); n != 1 {
+		t.Errorf("verify requests = %d, want 1", n)
+	}
+}
+
+func TestTokenManager_MinRefreshInterval(t *testing.T) {
+	manager := NewTokenManager(nil, TokenManagerConfig{})
+
+	// 既に期限切れのトークンでも最短間隔は待つ
+	manager.issuedAt = time.Now()
+	manager.expiresAt = manager.issuedAt.Add(-time.Second)
+	if delay := manager.nextRefreshDelay(); delay != time.Second {
+		t.Errorf("nextRefreshDelay() = %v, want 1s", delay)
+	}
+}
+
+func TestTokenManager_ConcurrentStart(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	server := authtest.NewServer(authtest.Config{})
+	defer server.Close()
+	server.AddClient("test-client", &privateKey.PublicKey, nil)
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:    server.URL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	manager := NewTokenManager(client, TokenManagerConfig{})
+
+	// 初回認証に失敗した場合は開始済みにならない
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := manager.Start(canceled); err == nil {
+		t.Fatal("Start() with canceled context should fail")
+	}
+
+	// 初回認証中に呼ばれたStartは開始しない
+	server.InjectFault("/verify", authtest.Fault{Delay: 100 * time.Millisecond})
+	var started atomic.Int32
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if manager.Start(context.Background()) == nil {
+				started.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	manager.Stop()
+
+	if n := started.Load(); n != 1 {
+		t.Errorf("successful Start() calls = %d, want 1", n)
+	}
+}
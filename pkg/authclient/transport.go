@@ -0,0 +1,106 @@
+package authclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Transport はアクセストークンをAuthorizationヘッダーに付与するhttp.RoundTripper
+// トークン未取得の場合は最初のリクエスト時に認証し、
+// 401が返された場合は1度だけ再認証してリクエストを再送します
+type Transport struct {
+	// Client はトークンの取得と再認証に使用する認証クライアント
+	Client *Client
+
+	// Base は実際にリクエストを送信するRoundTripper（nilの場合はhttp.DefaultTransport）
+	Base http.RoundTripper
+}
+
+// NewTransport は新しいTransportを作成します
+func NewTransport(client *Client, base http.RoundTripper) *Transport {
+	return &Transport{
+		Client: client,
+		Base:   base,
+	}
+}
+
+// RoundTrip はhttp.RoundTripperの実装です
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	// 初回利用時は認証
+	token := t.Client.GetAccessToken()
+	if token == "" {
+		if err := t.Client.RenewAccessToken(ctx); err != nil {
+			closeRequestBody(req)
+			return nil, fmt.Errorf("failed to authenticate: %w", err)
+		}
+		token = t.Client.GetAccessToken()
+	}
+
+	resp, err := t.base().RoundTrip(authorizedRequest(req, token, req.Body))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// ボディを再送できない場合は401をそのまま返す
+	if !isReplayable(req) {
+		return resp, nil
+	}
+
+	// 他のリクエストが既に再認証済みであれば新しいトークンをそのまま使う
+	// 新しいトークンを取得できなかった場合（キャッシュからの応答を含む）は401をそのまま返す
+	newToken := t.Client.GetAccessToken()
+	if newToken == token {
+		t.Client.logger.Info("access token rejected by peer, re-authenticating",
+			"client_id", t.Client.clientID, "host", req.URL.Host)
+		if err := t.Client.RenewAccessToken(ctx); err != nil {
+			return resp, nil
+		}
+		newToken = t.Client.GetAccessToken()
+	}
+
+	var body io.ReadCloser
+	if req.GetBody != nil {
+		body, err = req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+	}
+
+	// 最初のレスポンスを破棄して再送
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	return t.base().RoundTrip(authorizedRequest(req, newToken, body))
+}
+
+// base は使用するRoundTripperを返します
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// authorizedRequest はBearerトークンを付与したリクエストの複製を作成します
+// RoundTripperは元のリクエストを変更してはならないため複製します
+func authorizedRequest(req *http.Request, token string, body io.ReadCloser) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.Body = body
+	clone.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	return clone
+}
+
+// isReplayable はリクエストボディを再送できるかどうかを判定します
+func isReplayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// closeRequestBody はリクエストを送信しない場合にボディを閉じます
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
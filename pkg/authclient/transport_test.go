@@ -0,0 +1,163 @@
+package authclient
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/authtest"
+)
+
+func TestTransport_InjectsTokenAndReauthenticatesOn401(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	// 認証のたびに token-1, token-2, ... を発行するWorker
+	authServer, issued := setupTokenServer(t, nil)
+	defer authServer.Close()
+
+	// token-2のみを受け付けるピア
+	var requests atomic.Int32
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "echo:%s", body)
+	}))
+	defer peer.Close()
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:    authServer.URL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	httpClient := &http.Client{Transport: NewTransport(client, nil)}
+
+	resp, err := httpClient.Post(peer.URL, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "echo:payload" {
+		t.Errorf("body = %q, want echo:payload", body)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("peer received %d requests, want 2", got)
+	}
+	if got := issued.Load(); got != 2 {
+		t.Errorf("tokens issued = %d, want 2", got)
+	}
+}
+
+func TestTransport_NonReplayableBody(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	authServer, issued := setupTokenServer(t, nil)
+	defer authServer.Close()
+
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer peer.Close()
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:    authServer.URL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	// GetBodyを持たないボディは再送されない
+	req, err := http.NewRequest(http.MethodPost, peer.URL, io.NopCloser(strings.NewReader("payload")))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	resp, err := (&http.Client{Transport: NewTransport(client, nil)}).Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", resp.StatusCode)
+	}
+	if got := issued.Load(); got != 1 {
+		t.Errorf("tokens issued = %d, want 1 (no re-authentication)", got)
+	}
+}
+
+func TestTransport_StaleReauthenticationOn401(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	server := authtest.NewServer(authtest.Config{})
+	defer server.Close()
+	server.AddClient("test-client", &privateKey.PublicKey, nil)
+
+	var requests atomic.Int32
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer peer.Close()
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:     server.URL,
+		ClientID:    "test-client",
+		PrivateKey:  privateKey,
+		SecretCache: &SecretCacheConfig{Path: filepath.Join(t.TempDir(), "secrets.cache"), MaxStaleness: time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	// Workerに到達できず、再認証はキャッシュから応答する
+	server.InjectFault("", authtest.Fault{Status: http.StatusBadGateway})
+
+	resp, err := (&http.Client{Transport: NewTransport(client, nil)}).Get(peer.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	// 新しいトークンを取得できないため、同じトークンで再送しない
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", resp.StatusCode)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("peer received %d requests, want 1", got)
+	}
+}
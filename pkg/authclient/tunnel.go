@@ -0,0 +1,103 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// UnregisterTunnel は自身のトンネル登録を削除し、削除した登録内容を返します
+// グレースフルシャットダウン時に呼び出すと、他のクライアントが停止したURLに接続しなくなります
+func (c *Client) UnregisterTunnel() (*TunnelData, error) {
+	return c.UnregisterTunnelContext(context.Background())
+}
+
+// UnregisterTunnelContext はcontext付きで自身のトンネル登録を削除します
+func (c *Client) UnregisterTunnelContext(ctx context.Context) (*TunnelData, error) {
+	var tunnelResp TunnelGetResponse
+	if err := c.tunnelRequest(ctx, http.MethodDelete, "/tunnel/"+url.PathEscape(c.clientID), &tunnelResp); err != nil {
+		return nil, classifyTunnelError(err)
+	}
+	if !tunnelResp.Success {
+		return nil, fmt.Errorf("%w: %s", ErrUnauthorized, tunnelResp.Error)
+	}
+
+	// 以降の認証でトンネルURLを送信しない
+	c.mu.Lock()
+	c.tunnelUrl = ""
+	c.mu.Unlock()
+
+	c.logger.Info("tunnel unregistered", "client_id", c.clientID)
+
+	return &tunnelResp.Data, nil
+}
+
+// ListTunnels は登録されている全てのトンネルを返します
+// 各トンネルのTokenは空です。Workerが一覧の取得を許可していない場合はErrForbiddenを返します
+func (c *Client) ListTunnels() ([]TunnelData, error) {
+	return c.ListTunnelsContext(context.Background())
+}
+
+// ListTunnelsContext はcontext付きで登録されている全てのトンネルを返します
+func (c *Client) ListTunnelsContext(ctx context.Context) ([]TunnelData, error) {
+	var listResp TunnelListResponse
+	if err := c.tunnelRequest(ctx, http.MethodGet, "/tunnels", &listResp); err != nil {
+		return nil, err
+	}
+	if !listResp.Success {
+		return nil, fmt.Errorf("%w: %s", ErrUnauthorized, listResp.Error)
+	}
+
+	return listResp.Data, nil
+}
+
+// GetTunnelFor は他のクライアントのトンネル情報を取得します
+// 登録がない場合はErrTunnelNotFound、Workerが参照を許可していない場合はErrForbiddenを返します
+func (c *Client) GetTunnelFor(clientID string) (*TunnelData, error) {
+	return c.GetTunnelForContext(context.Background(), clientID)
+}
+
+// GetTunnelForContext はcontext付きで他のクライアントのトンネル情報を取得します
+func (c *Client) GetTunnelForContext(ctx context.Context, clientID string) (*TunnelData, error) {
+	if clientID == "" {
+		return nil, fmt.Errorf("%w: clientID is required", ErrInvalidConfig)
+	}
+
+	var tunnelResp TunnelGetResponse
+	if err := c.tunnelRequest(ctx, http.MethodGet, "/tunnel/"+url.PathEscape(clientID), &tunnelResp); err != nil {
+		return nil, classifyTunnelError(err)
+	}
+	if !tunnelResp.Success {
+		return nil, fmt.Errorf("%w: %s", ErrUnauthorized, tunnelResp.Error)
+	}
+
+	return &tunnelResp.Data, nil
+}
+
+// tunnelRequest はアクセストークン付きでトンネルAPIを呼び出し、レスポンスをoutにデコードします
+func (c *Client) tunnelRequest(ctx context.Context, method, path string, out any) error {
+	accessToken := c.GetAccessToken()
+	if accessToken == "" {
+		return fmt.Errorf("%w: accessToken is required, please authenticate first", ErrInvalidConfig)
+	}
+
+	body, err := withFailover(c, ctx, func(e *endpoint) ([]byte, error) {
+		return c.doRequest(ctx, method, e.url+path, nil, accessToken)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.decodeResponse(path, body, out)
+}
+
+// classifyTunnelError はトンネルAPIの404をErrTunnelNotFoundとして扱えるようにします
+func classifyTunnelError(err error) error {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %w", ErrTunnelNotFound, err)
+	}
+	return err
+}
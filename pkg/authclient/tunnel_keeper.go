@@ -0,0 +1,321 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TunnelKeeperConfig はTunnelKeeperの設定
+type TunnelKeeperConfig struct {
+	// TunnelURL は登録するトンネルURL（SetTunnelURLで後から変更可能）
+	TunnelURL string
+
+	// Interval は登録を更新する間隔（デフォルト: 10分）
+	Interval time.Duration
+
+	// CheckInterval はGetTunnelで登録内容を確認する間隔（デフォルト: 1分）
+	// 登録が消えている・URLやトークンが異なる場合は即座に再登録します
+	CheckInterval time.Duration
+
+	// MinBackoff は登録失敗時の最初の待機時間（デフォルト: 1秒）
+	MinBackoff time.Duration
+
+	// MaxBackoff は登録失敗時の最大待機時間（デフォルト: 1分）
+	MaxBackoff time.Duration
+}
+
+// TunnelState はトンネル登録の状態
+type TunnelState struct {
+	// TunnelURL は登録しようとしているトンネルURL
+	TunnelURL string
+
+	// Registered はWorkerの登録内容がTunnelURLと現在のアクセストークンに一致している場合にtrue
+	Registered bool
+
+	// Data はWorkerに登録されている内容（最後に成功した登録・確認の結果）
+	Data TunnelData
+
+	// UpdatedAt はWorkerで登録が更新された日時（Data.UpdatedAt、返されなかった場合はゼロ値）
+	UpdatedAt time.Time
+
+	// LastSync は最後に登録・確認を行った日時
+	LastSync time.Time
+
+	// LastError は直近の登録・確認で発生したエラー（成功時はnil）
+	LastError error
+}
+
+// TunnelKeeper はトンネル登録を定期的に更新し、トンネルURLの変更やトークンの拒否に追従します
+type TunnelKeeper struct {
+	client  *Client
+	config  TunnelKeeperConfig
+	trigger chan struct{}
+
+	syncMu sync.Mutex // 登録処理を直列化
+
+	mu      sync.RWMutex
+	state   TunnelState
+	changed chan struct{}
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewTunnelKeeper は新しいTunnelKeeperを作成します
+func NewTunnelKeeper(client *Client, config TunnelKeeperConfig) *TunnelKeeper {
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Minute
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = time.Minute
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = time.Minute
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = config.MinBackoff
+	}
+
+	return &TunnelKeeper{
+		client:  client,
+		config:  config,
+		trigger: make(chan struct{}, 1),
+		state:   TunnelState{TunnelURL: config.TunnelURL},
+		changed: make(chan struct{}),
+	}
+}
+
+// Start は初回の登録を同期的に実行し、バックグラウンドでの更新を開始します
+// 更新はctxがキャンセルされるかStopが呼ばれるまで続きます
+// 初回の登録に失敗した場合は開始せず、再びStartを呼び出せます
+func (k *TunnelKeeper) Start(ctx context.Context) error {
+	loopCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	// 同時に呼ばれたStartが両方とも開始しないよう、初回の処理の前に開始済みにする
+	k.mu.Lock()
+	if k.done != nil {
+		k.mu.Unlock()
+		cancel()
+		return errors.New("tunnel keeper already started")
+	}
+	k.cancel = cancel
+	k.done = done
+	k.mu.Unlock()
+
+	if err := k.Sync(loopCtx); err != nil {
+		cancel()
+		k.mu.Lock()
+		k.cancel = nil
+		k.done = nil
+		k.mu.Unlock()
+		close(done)
+		return err
+	}
+
+	go k.run(loopCtx, done)
+	return nil
+}
+
+// Stop はバックグラウンドでの更新を停止し、終了を待ちます
+func (k *TunnelKeeper) Stop() {
+	k.mu.RLock()
+	cancel, done := k.cancel, k.done
+	k.mu.RUnlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// SetTunnelURL はトンネルURLを変更し、実行中であれば即座に再登録します
+// cloudflaredの再起動でクイックトンネルのURLが変わった場合に呼び出してください
+func (k *TunnelKeeper) SetTunnelURL(tunnelURL string) {
+	k.mu.Lock()
+	if k.state.TunnelURL == tunnelURL {
+		k.mu.Unlock()
+		return
+	}
+	k.state.TunnelURL = tunnelURL
+	k.state.Registered = false
+	k.notifyLocked()
+	k.mu.Unlock()
+
+	select {
+	case k.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// State は現在の登録状態を返します
+func (k *TunnelKeeper) State() TunnelState {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.state
+}
+
+// Changed は登録状態が次に変更されたときにcloseされるチャネルを返します
+// 変更を受け取った後は再度Changedを呼んで次の変更を待ってください
+func (k *TunnelKeeper) Changed() <-chan struct{} {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.changed
+}
+
+// Sync は即座にトンネルを登録します
+// アクセストークンがない場合やWorkerに拒否された場合は再認証してから登録します
+func (k *TunnelKeeper) Sync(ctx context.Context) error {
+	k.syncMu.Lock()
+	defer k.syncMu.Unlock()
+
+	tunnelURL := k.State().TunnelURL
+	if tunnelURL == "" {
+		err := fmt.Errorf("%w: tunnel URL is required", ErrInvalidConfig)
+		k.record(tunnelURL, nil, err)
+		return err
+	}
+
+	resp, err := k.register(ctx, tunnelURL)
+	if resp != nil {
+		k.record(tunnelURL, &resp.Data, nil)
+		k.client.logger.Info("tunnel registration refreshed",
+			"client_id", k.client.clientID, "tunnel_url", tunnelURL)
+		return nil
+	}
+
+	k.record(tunnelURL, nil, err)
+	return err
+}
+
+// Verify はGetTunnelでWorkerの登録内容を確認し、一致しない場合は再登録します
+func (k *TunnelKeeper) Verify(ctx context.Context) error {
+	tunnelURL := k.State().TunnelURL
+
+	resp, err := k.client.GetTunnelContext(ctx)
+	switch {
+	case err == nil && resp.Data.TunnelUrl == tunnelURL && resp.Data.Token == k.client.GetAccessToken():
+		k.record(tunnelURL, &resp.Data, nil)
+		return nil
+	case err == nil, errors.Is(err, ErrNotFound), errors.Is(err, ErrUnauthorized), errors.Is(err, ErrInvalidConfig):
+		// 登録が消えている・他の値で上書きされている・トークンが拒否された場合は再登録
+		k.client.logger.Info("tunnel registration out of date, re-registering",
+			"client_id", k.client.clientID, "tunnel_url", tunnelURL, "error", err)
+		return k.Sync(ctx)
+	default:
+		k.record(tunnelURL, nil, err)
+		return err
+	}
+}
+
+// register はトンネルを登録し、トークンが拒否された場合は再認証して1回だけやり直します
+func (k *TunnelKeeper) register(ctx context.Context, tunnelURL string) (*TunnelRegisterResponse, error) {
+	if k.client.GetAccessToken() == "" {
+		if err := k.client.RenewAccessToken(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := k.client.RegisterTunnelContext(ctx, tunnelURL)
+	if !errors.Is(err, ErrUnauthorized) {
+		return resp, err
+	}
+
+	k.client.logger.Info("access token rejected by tunnel registration, re-authenticating",
+		"client_id", k.client.clientID)
+	if err := k.client.RenewAccessToken(ctx); err != nil {
+		return nil, err
+	}
+	return k.client.RegisterTunnelContext(ctx, tunnelURL)
+}
+
+// record は登録・確認の結果を状態に反映し、変化があれば通知します
+func (k *TunnelKeeper) record(tunnelURL string, data *TunnelData, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// 処理中にURLが変更された場合は古い結果を反映しない
+	if k.state.TunnelURL != tunnelURL {
+		return
+	}
+
+	previous := k.state
+	k.state.LastSync = time.Now()
+	k.state.LastError = err
+	if data != nil {
+		k.state.Data = *data
+		// updatedAtが返されなかった場合は不明としてゼロ値にする
+		k.state.UpdatedAt = time.Time{}
+		if data.UpdatedAt > 0 {
+			k.state.UpdatedAt = time.UnixMilli(data.UpdatedAt)
+		}
+		k.state.Registered = data.TunnelUrl == tunnelURL
+	} else if err != nil {
+		k.state.Registered = false
+	}
+
+	if previous.Registered != k.state.Registered ||
+		previous.Data != k.state.Data ||
+		(previous.LastError == nil) != (err == nil) {
+		k.notifyLocked()
+	}
+}
+
+// notifyLocked は変更を通知します（k.muを保持して呼び出すこと）
+func (k *TunnelKeeper) notifyLocked() {
+	close(k.changed)
+	k.changed = make(chan struct{})
+}
+
+// run は定期的な登録の更新と確認を繰り返します
+func (k *TunnelKeeper) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	syncTimer := time.NewTimer(k.config.Interval)
+	defer syncTimer.Stop()
+	checkTicker := time.NewTicker(k.config.CheckInterval)
+	defer checkTicker.Stop()
+
+	backoff := k.config.MinBackoff
+	for {
+		var err error
+		synced := true
+		select {
+		case <-ctx.Done():
+			return
+		case <-k.trigger:
+			err = k.Sync(ctx)
+		case <-syncTimer.C:
+			err = k.Sync(ctx)
+		case <-checkTicker.C:
+			err = k.Verify(ctx)
+			synced = false
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			k.client.logger.Warn("tunnel registration failed",
+				"client_id", k.client.clientID, "retry_in", backoff, "error", err)
+			syncTimer.Reset(backoff)
+			backoff *= 2
+			if backoff > k.config.MaxBackoff {
+				backoff = k.config.MaxBackoff
+			}
+			continue
+		}
+
+		backoff = k.config.MinBackoff
+		if synced {
+			syncTimer.Reset(k.config.Interval)
+		}
+	}
+}
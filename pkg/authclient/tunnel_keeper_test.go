@@ -0,0 +1,191 @@
+package authclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/authtest"
+)
+
+func setupTunnelKeeper(t *testing.T, config TunnelKeeperConfig) (*authtest.Server, *Client, *TunnelKeeper) {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	server := authtest.NewServer(authtest.Config{})
+	t.Cleanup(server.Close)
+	server.AddClient("test-client", &privateKey.PublicKey, nil)
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:    server.URL,
+		ClientID:   "test-client",
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	return server, client, NewTunnelKeeper(client, config)
+}
+
+// waitTunnel はWorkerの登録内容がcondを満たすまで待ちます
+func waitTunnel(t *testing.T, server *authtest.Server, cond func(authtest.TunnelData) bool) authtest.TunnelData {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if tunnel, ok := server.Tunnel("test-client"); ok && cond(tunnel) {
+			return tunnel
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for tunnel registration")
+	return authtest.TunnelData{}
+}
+
+func TestTunnelKeeper_StartAndURLChange(t *testing.T) {
+	server, _, keeper := setupTunnelKeeper(t, TunnelKeeperConfig{
+		TunnelURL: "https://first.trycloudflare.com",
+	})
+
+	if err := keeper.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer keeper.Stop()
+
+	state := keeper.State()
+	if !state.Registered || state.UpdatedAt.IsZero() || state.LastError != nil {
+		t.Fatalf("State() = %+v, want registered", state)
+	}
+	if tunnel, _ := server.Tunnel("test-client"); tunnel.TunnelUrl != "https://first.trycloudflare.com" {
+		t.Fatalf("registered URL = %q", tunnel.TunnelUrl)
+	}
+
+	keeper.SetTunnelURL("https://second.trycloudflare.com")
+	waitTunnel(t, server, func(d authtest.TunnelData) bool {
+		return d.TunnelUrl == "https://second.trycloudflare.com"
+	})
+
+	deadline := time.After(5 * time.Second)
+	for !keeper.State().Registered {
+		select {
+		case <-keeper.Changed():
+		case <-deadline:
+			t.Fatal("timed out waiting for state change")
+		}
+	}
+	if got := keeper.State().Data.TunnelUrl; got != "https://second.trycloudflare.com" {
+		t.Errorf("State().Data.TunnelUrl = %q", got)
+	}
+}
+
+func TestTunnelKeeper_ReauthenticatesWhenTokenRejected(t *testing.T) {
+	server, client, keeper := setupTunnelKeeper(t, TunnelKeeperConfig{
+		TunnelURL: "https://tunnel.example.com",
+	})
+
+	ctx := context.Background()
+	if err := keeper.Sync(ctx); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	firstToken := client.GetAccessToken()
+
+	server.RevokeAccessToken("test-client")
+	if err := keeper.Sync(ctx); err != nil {
+		t.Fatalf("Sync() after revoke error = %v", err)
+	}
+
+	secondToken := client.GetAccessToken()
+	if secondToken == firstToken || secondToken != server.AccessToken("test-client") {
+		t.Errorf("token was not refreshed after rejection")
+	}
+	if tunnel, _ := server.Tunnel("test-client"); tunnel.Token != secondToken {
+		t.Errorf("registered token is not the refreshed token")
+	}
+}
+
+func TestTunnelKeeper_VerifyReregistersAfterTokenRotation(t *testing.T) {
+	server, client, keeper := setupTunnelKeeper(t, TunnelKeeperConfig{
+		TunnelURL:     "https://tunnel.example.com",
+		CheckInterval: 20 * time.Millisecond,
+	})
+
+	if err := keeper.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer keeper.Stop()
+
+	// 別の経路で再認証するとWorkerに登録されたトークンが古くなる
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	token := client.GetAccessToken()
+
+	waitTunnel(t, server, func(d authtest.TunnelData) bool {
+		return d.Token == token
+	})
+}
+
+func TestTunnelKeeper_RequiresURL(t *testing.T) {
+	_, _, keeper := setupTunnelKeeper(t, TunnelKeeperConfig{})
+
+	if err := keeper.Start(context.Background()); err == nil {
+		t.Fatal("Start() without tunnel URL should fail")
+	}
+	if keeper.State().LastError == nil {
+		t.Error("State().LastError should be set")
+	}
+}
+
+func TestTunnelKeeper_ConcurrentStart(t *testing.T) {
+	server, _, keeper := setupTunnelKeeper(t, TunnelKeeperConfig{})
+
+	// 初回の登録に失敗した場合は開始済みにならない
+	if err := keeper.Start(context.Background()); err == nil {
+		t.Fatal("Start() without tunnel URL should fail")
+	}
+	keeper.SetTunnelURL("https://tunnel.example.com")
+
+	// 初回の登録中に呼ばれたStartは開始しない
+	server.InjectFault("/verify", authtest.Fault{Delay: 100 * time.Millisecond})
+	var started atomic.Int32
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if keeper.Start(context.Background()) == nil {
+				started.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	keeper.Stop()
+
+	if n := started.Load(); n != 1 {
+		t.Errorf("successful Start() calls = %d, want 1", n)
+	}
+}
+
+func TestTunnelKeeper_UnknownUpdatedAt(t *testing.T) {
+	_, _, keeper := setupTunnelKeeper(t, TunnelKeeperConfig{TunnelURL: "https://tunnel.example.com"})
+
+	keeper.record("https://tunnel.example.com", &TunnelData{TunnelUrl: "https://tunnel.example.com", UpdatedAt: 1700000000000}, nil)
+	if got := keeper.State().UpdatedAt; !got.Equal(time.UnixMilli(1700000000000)) {
+		t.Errorf("State().UpdatedAt = %v", got)
+	}
+
+	// updatedAtを返さないWorkerではゼロ値になる
+	keeper.record("https://tunnel.example.com", &TunnelData{TunnelUrl: "https://tunnel.example.com"}, nil)
+	if got := keeper.State().UpdatedAt; !got.IsZero() {
+		t.Errorf("State().UpdatedAt = %v, want zero", got)
+	}
+}
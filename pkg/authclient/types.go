@@ -1,9 +1,13 @@
 package authclient
 
 import (
+	"crypto"
 	"crypto/rsa"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/metrics"
 )
 
 // ClientConfig はクライアントの設定
@@ -11,12 +15,28 @@ type ClientConfig struct {
 	// BaseURL はCloudflare WorkerのベースURL (例: https://your-worker.workers.dev)
 	BaseURL string
 
+	// BaseURLs は優先順に並んだ複数のWorkerのベースURL（BaseURLとどちらか一方を指定）
+	// Workerに到達できない場合は次のURLに切り替えます
+	BaseURLs []string
+
+	// EndpointCooldown は到達できなかったエンドポイントを後回しにする期間（デフォルト: 30秒）
+	EndpointCooldown time.Duration
+
 	// ClientID はクライアント識別子
 	ClientID string
 
-	// PrivateKey はRSA秘密鍵
+	// PrivateKey はRSA秘密鍵（PrivateKeyとSignerのどちらか一方を指定）
 	PrivateKey *rsa.PrivateKey
 
+	// Signer はチャレンジの署名に使用する署名器（オプション）
+	// エージェントや別プロセス、HSMに保持された鍵で署名する場合に使用します
+	// 公開鍵はSigner.Public()から取得されます
+	Signer crypto.Signer
+
+	// Algorithm は署名アルゴリズム（オプション、RS256/PS256/ES256/EdDSA）
+	// 空の場合は鍵の型から選択します（RSA: RS256、ECDSA P-256: ES256、Ed25519: EdDSA）
+	Algorithm string
+
 	// HTTPClient はカスタムHTTPクライアント（オプション）
 	HTTPClient *http.Client
 
@@ -37,6 +57,43 @@ type ClientConfig struct {
 
 	// TunnelUrl はCloudflare TunnelのURL（オプション）
 	TunnelUrl string
+
+	// ClockSkew はチャレンジの有効期限判定で許容するWorkerとの時計のずれ（デフォルト: 5秒）
+	ClockSkew time.Duration
+
+	// StrictTransport がtrueの場合、ループバック以外へのHTTP接続を拒否します
+	StrictTransport bool
+
+	// PinnedSPKIHashes はWorkerの検証済み証明書チェーンに含まれるべき公開鍵のハッシュ（オプション）
+	// SubjectPublicKeyInfoのSHA-256をBase64エンコードした値（SPKIHashで計算可能）
+	// 指定した場合、いずれにも一致しない接続からのレスポンスは拒否されます
+	PinnedSPKIHashes []string
+
+	// SecretCache はSecret変数の暗号化オフラインキャッシュの設定（オプション）
+	// 指定した場合、Workerに到達できないときに最大経過時間以内のキャッシュを返します
+	SecretCache *SecretCacheConfig
+
+	// Logger は構造化ログの出力先（オプション、nilの場合は出力しない）
+	// トークンやSecret値はログに出力されません
+	Logger *slog.Logger
+
+	// Metrics はメトリクスの登録先（オプション、nilの場合は記録しない）
+	// 同じレジストリを複数のクライアントで共有できます
+	Metrics *metrics.Registry
+
+	// MaxResponseBytes はWorkerのレスポンスボディの最大サイズ（デフォルト: 1MiB）
+	MaxResponseBytes int64
+
+	// StrictResponses がtrueの場合、未知のフィールドや必須フィールドの欠落、
+	// Content-Typeのないレスポンスを*ProtocolErrorとして拒否します
+	StrictResponses bool
+
+	// Hooks はチャレンジ・署名・検証などの前後に呼ばれるコールバック（オプション）
+	// トレーシングのスパンを付与する場合などに使用します
+	Hooks Hooks
+
+	// RetryPolicy は認証失敗時のリトライポリシー（オプション、nilの場合はリトライなし）
+	RetryPolicy RetryPolicy
 }
 
 // ChallengeResponse はチャレンジエンドポイントからのレスポンス
@@ -59,6 +116,9 @@ type VerifyRequest struct {
 	// Signature はBase64エンコードされた署名
 	Signature string `json:"signature"`
 
+	// Algorithm は署名アルゴリズム（RS256/PS256/ES256/EdDSA）
+	Algorithm string `json:"algorithm,omitempty"`
+
 	// RepoUrl はGitHubリポジトリのURL（オプション）
 	RepoUrl string `json:"repoUrl,omitempty"`
 
@@ -83,6 +143,9 @@ type VerifyResponse struct {
 	// AccessToken はBase64エンコードされた認証トークン
 	AccessToken string `json:"accessToken"`
 
+	// AccessTokenExpiresAt はアクセストークンの有効期限（Unix時間、Workerが返す場合のみ）
+	AccessTokenExpiresAt int64 `json:"accessTokenExpiresAt,omitempty"`
+
 	// SecretData はSecret変数のマップ
 	SecretData map[string]string `json:"secretData"`
 
@@ -91,6 +154,13 @@ type VerifyResponse struct {
 
 	// Error はエラーメッセージ（認証失敗時）
 	Error string `json:"error,omitempty"`
+
+	// Stale はWorkerに到達できずオフラインキャッシュから返された場合にtrue
+	// この場合AccessTokenは空です
+	Stale bool `json:"-"`
+
+	// FetchedAt はSecret変数をWorkerから取得した日時
+	FetchedAt time.Time `json:"-"`
 }
 
 // ErrorResponse はエラーレスポンス
@@ -128,7 +198,7 @@ type TunnelData struct {
 	// TunnelUrl はトンネルURL
 	TunnelUrl string `json:"tunnelUrl"`
 
-	// Token はアクセストークン
+	// Token はアクセストークン（自身のトンネルの場合のみ。他のクライアントのトンネルでは空）
 	Token string `json:"token"`
 
 	// UpdatedAt は更新日時（Unix時間ミリ秒）
@@ -161,3 +231,39 @@ type TunnelGetResponse struct {
 	// Error はエラーメッセージ
 	Error string `json:"error,omitempty"`
 }
+
+// TunnelListResponse はトンネル一覧レスポンス
+type TunnelListResponse struct {
+	// Success は成功フラグ
+	Success bool `json:"success"`
+
+	// Data はトンネル情報の一覧
+	Data []TunnelData `json:"data"`
+
+	// Error はエラーメッセージ
+	Error string `json:"error,omitempty"`
+}
+
+// IntrospectRequest はトークン照会リクエスト
+type IntrospectRequest struct {
+	// Token は照会するアクセストークン
+	Token string `json:"token"`
+}
+
+// IntrospectResponse はトークン照会レスポンス
+type IntrospectResponse struct {
+	// Success は成功フラグ
+	Success bool `json:"success"`
+
+	// Active はトークンが有効な場合にtrue
+	Active bool `json:"active"`
+
+	// ClientID はトークンを発行されたクライアントID（有効な場合のみ）
+	ClientID string `json:"clientId,omitempty"`
+
+	// ExpiresAt はトークンの有効期限（Unix時間、有効な場合のみ）
+	ExpiresAt int64 `json:"expiresAt,omitempty"`
+
+	// Error はエラーメッセージ
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,182 @@
+package authmiddleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/authclient"
+)
+
+// IntrospectionConfig はIntrospectionValidatorの設定
+type IntrospectionConfig struct {
+	// Client はWorkerへの問い合わせに使用する認証クライアント（必須）
+	// 自身のアクセストークンで問い合わせ、未取得・拒否された場合は認証します
+	Client *authclient.Client
+
+	// PositiveTTL は有効なトークンの結果をキャッシュする期間（デフォルト: 1分）
+	// トークンの有効期限がそれより前の場合は有効期限までキャッシュします
+	PositiveTTL time.Duration
+
+	// NegativeTTL は無効なトークンの結果をキャッシュする期間（デフォルト: 10秒）
+	NegativeTTL time.Duration
+
+	// MaxEntries はキャッシュするトークンの最大数（デフォルト: 10000）
+	MaxEntries int
+
+	// FailOpen がtrueの場合、Workerに到達できないとき（ネットワークエラー・5xx）はリクエストを許可します
+	// falseの場合は503で拒否します（デフォルト）
+	FailOpen bool
+
+	// Logger は構造化ログの出力先（オプション、nilの場合は出力しない）
+	// トークンの値はログに出力されません
+	Logger *slog.Logger
+}
+
+// IntrospectionValidator はWorkerにトークンを問い合わせて検証するTokenValidator
+// 他のクライアントがWorkerから発行されたトークンで呼び出す場合に使用します
+// 一致したトークンの名前は"introspection"、FailOpenで許可した場合は"unverified"です
+type IntrospectionValidator struct {
+	config IntrospectionConfig
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]introspectionEntry
+}
+
+// introspectionEntry はキャッシュした照会結果
+type introspectionEntry struct {
+	active    bool
+	clientID  string
+	expiresAt time.Time
+}
+
+// NewIntrospectionValidator は新しいIntrospectionValidatorを作成します
+func NewIntrospectionValidator(config IntrospectionConfig) (*IntrospectionValidator, error) {
+	if config.Client == nil {
+		return nil, errors.New("authmiddleware: introspection client is required")
+	}
+	if config.PositiveTTL <= 0 {
+		config.PositiveTTL = time.Minute
+	}
+	if config.NegativeTTL <= 0 {
+		config.NegativeTTL = 10 * time.Second
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 10000
+	}
+	if config.Logger == nil {
+		config.Logger = slog.New(slog.DiscardHandler)
+	}
+
+	return &IntrospectionValidator{
+		config: config,
+		cache:  make(map[[sha256.Size]byte]introspectionEntry),
+	}, nil
+}
+
+// ValidateToken はTokenValidatorの実装です
+func (v *IntrospectionValidator) ValidateToken(ctx context.Context, token string) (TokenInfo, error) {
+	if token == "" {
+		return TokenInfo{}, ErrInvalidToken
+	}
+
+	// トークンそのものではなくハッシュ値をキーにする
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+
+	v.mu.Lock()
+	entry, ok := v.cache[key]
+	v.mu.Unlock()
+
+	if !ok || !now.Before(entry.expiresAt) {
+		resp, err := v.introspect(ctx, token)
+		if err != nil {
+			if v.config.FailOpen && workerUnavailable(err) {
+				v.config.Logger.Warn("worker unreachable, allowing request without introspection", "error", err)
+				return TokenInfo{Name: "unverified"}, nil
+			}
+			return TokenInfo{}, err
+		}
+
+		entry = v.newEntry(resp, now)
+		v.store(key, entry, now)
+	}
+
+	if !entry.active {
+		return TokenInfo{}, ErrInvalidToken
+	}
+	return TokenInfo{Name: "introspection", ClientID: entry.clientID}, nil
+}
+
+// introspect はWorkerにトークンを問い合わせ、自身のトークンが拒否された場合は再認証して1回だけやり直します
+func (v *IntrospectionValidator) introspect(ctx context.Context, token string) (*authclient.IntrospectResponse, error) {
+	client := v.config.Client
+	if client.GetAccessToken() == "" {
+		if err := client.RenewAccessToken(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := client.IntrospectTokenContext(ctx, token)
+	if !errors.Is(err, authclient.ErrUnauthorized) {
+		return resp, err
+	}
+
+	v.config.Logger.Info("access token rejected by introspection, re-authenticating")
+	if err := client.RenewAccessToken(ctx); err != nil {
+		return nil, err
+	}
+	return client.IntrospectTokenContext(ctx, token)
+}
+
+// newEntry は照会結果からキャッシュの有効期限を決めます
+func (v *IntrospectionValidator) newEntry(resp *authclient.IntrospectResponse, now time.Time) introspectionEntry {
+	if !resp.Active {
+		return introspectionEntry{expiresAt: now.Add(v.config.NegativeTTL)}
+	}
+
+	expiresAt := now.Add(v.config.PositiveTTL)
+	if resp.ExpiresAt > 0 {
+		if tokenExpiresAt := time.Unix(resp.ExpiresAt, 0); tokenExpiresAt.Before(expiresAt) {
+			expiresAt = tokenExpiresAt
+		}
+	}
+	return introspectionEntry{active: true, clientID: resp.ClientID, expiresAt: expiresAt}
+}
+
+// store は照会結果をキャッシュし、上限に達した場合は期限切れのものから破棄します
+func (v *IntrospectionValidator) store(key [sha256.Size]byte, entry introspectionEntry, now time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.cache[key]; !ok && len(v.cache) >= v.config.MaxEntries {
+		for k, e := range v.cache {
+			if !now.Before(e.expiresAt) {
+				delete(v.cache, k)
+			}
+		}
+		// 期限切れがなければ任意の1件を破棄
+		for k := range v.cache {
+			if len(v.cache) < v.config.MaxEntries {
+				break
+			}
+			delete(v.cache, k)
+		}
+	}
+	v.cache[key] = entry
+}
+
+// workerUnavailable はWorkerに到達できず照会できなかったエラーかどうかを判定します
+// ネットワークエラーと5xxのみが対象で、429などWorkerが応答したエラーは含みません
+func workerUnavailable(err error) bool {
+	if errors.Is(err, authclient.ErrNetworkError) || errors.Is(err, authclient.ErrStaleSecrets) {
+		return true
+	}
+
+	var httpErr *authclient.HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,264 @@
+package authmiddleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/authclient"
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/authtest"
+)
+
+// newTestClient はWorkerにクライアントを登録し、そのクライアントを作成します
+func newTestClient(t *testing.T, server *authtest.Server, clientID string) *authclient.Client {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	server.AddClient(clientID, &privateKey.PublicKey, nil)
+
+	client, err := authclient.NewClient(authclient.ClientConfig{
+		BaseURL:    server.URL,
+		ClientID:   clientID,
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
+
+// setupIntrospection はWorkerにサーバーと呼び出し元のクライアントを登録し、
+// 呼び出し元が取得したアクセストークンを返します
+func setupIntrospection(t *testing.T, config IntrospectionConfig) (*authtest.Server, *IntrospectionValidator, string) {
+	t.Helper()
+
+	server := authtest.NewServer(authtest.Config{})
+	t.Cleanup(server.Close)
+
+	caller := newTestClient(t, server, "caller-client")
+	resp, err := caller.Authenticate()
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	config.Client = newTestClient(t, server, "server-client")
+	validator, err := NewIntrospectionValidator(config)
+	if err != nil {
+		t.Fatalf("NewIntrospectionValidator() error = %v", err)
+	}
+	return server, validator, resp.AccessToken
+}
+
+func TestIntrospectionValidator_CachesResults(t *testing.T) {
+	server, validator, token := setupIntrospection(t, IntrospectionConfig{
+		PositiveTTL: 100 * time.Millisecond,
+		NegativeTTL: time.Hour,
+	})
+	ctx := context.Background()
+
+	for range 3 {
+		info, err := validator.ValidateToken(ctx, token)
+		if err != nil {
+			t.Fatalf("ValidateToken() error = %v", err)
+		}
+		if info.Name != "introspection" || info.ClientID != "caller-client" {
+			t.Errorf("ValidateToken() = %+v", info)
+		}
+	}
+	for range 2 {
+		if _, err := validator.ValidateToken(ctx, "unknown-token"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("ValidateToken(unknown) error = %v, want ErrInvalidToken", err)
+		}
+	}
+	if n := server.Requests("/introspect"); n != 2 {
+		t.Errorf("introspect requests = %d, want 2", n)
+	}
+
+	// 有効期間が過ぎると取り消されたトークンは拒否される
+	server.RevokeAccessToken("caller-client")
+	time.Sleep(150 * time.Millisecond)
+	if _, err := validator.ValidateToken(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ValidateToken() after revoke error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestIntrospectionValidator_ReauthenticatesWhenRejected(t *testing.T) {
+	server, validator, token := setupIntrospection(t, IntrospectionConfig{})
+	ctx := context.Background()
+
+	if _, err := validator.ValidateToken(ctx, token); err != nil {
+		t.Fatalf("ValidateToken() error = %v", err)
+	}
+
+	// サーバー自身のトークンが失効しても再認証して照会する
+	server.RevokeAccessToken("server-client")
+	if _, err := validator.ValidateToken(ctx, "other-token"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ValidateToken() error = %v, want ErrInvalidToken", err)
+	}
+	if server.AccessToken("server-client") == "" {
+		t.Error("server client should have re-authenticated")
+	}
+}
+
+func TestIntrospectionValidator_WorkerUnavailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		failOpen bool
+		status   int
+		wantCode int
+	}{
+		{name: "fail closed", failOpen: false, status: http.StatusServiceUnavailable, wantCode: http.StatusServiceUnavailable},
+		{name: "fail open", failOpen: true, status: http.StatusServiceUnavailable, wantCode: http.StatusOK},
+		{name: "rate limited", failOpen: true, status: http.StatusTooManyRequests, wantCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, validator, token := setupIntrospection(t, IntrospectionConfig{FailOpen: tt.failOpen})
+			server.InjectFault("/introspect", authtest.Fault{Status: tt.status})
+
+			var got TokenInfo
+			handler := NewTunnelAuthMiddleware(Config{TokenValidator: validator}).Middleware(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					got, _ = TokenInfoFromContext(r.Context())
+				}))
+
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusOK && got.Name != "unverified" {
+				t.Errorf("TokenInfoFromContext() = %+v, want unverified", got)
+			}
+		})
+	}
+}
+
+func TestIntrospectionValidator_Middleware(t *testing.T) {
+	_, validator, token := setupIntrospection(t, IntrospectionConfig{})
+
+	var got TokenInfo
+	handler := NewTunnelAuthMiddleware(Config{TokenValidator: validator}).Middleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, _ = TokenInfoFromContext(r.Context())
+		}))
+
+	for _, tt := range []struct {
+		token    string
+		wantCode int
+	}{
+		{token: token, wantCode: http.StatusOK},
+		{token: "forged-token", wantCode: http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("GET", "/api/test", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantCode {
+			t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+		}
+	}
+	if got.ClientID != "caller-client" {
+		t.Errorf("TokenInfoFromContext() = %+v, want caller-client", got)
+	}
+}
+
+func TestIntrospectionValidator_PeerResolver(t *testing.T) {
+	server := authtest.NewServer(authtest.Config{})
+	t.Cleanup(server.Close)
+
+	peer := newTestClient(t, server, "server-client")
+	caller := newTestClient(t, server, "caller-client")
+	for _, client := range []*authclient.Client{peer, caller} {
+		if _, err := client.Authenticate(); err != nil {
+			t.Fatalf("Authenticate() error = %v", err)
+		}
+	}
+	callerToken := caller.GetAccessToken()
+
+	introspection, err := NewIntrospectionValidator(IntrospectionConfig{Client: peer})
+	if err != nil {
+		t.Fatalf("NewIntrospectionValidator() error = %v", err)
+	}
+	assertions, err := NewPeerAssertionValidator(PeerAssertionConfig{
+		ClientID: "server-client",
+		Clients:  map[string]crypto.PublicKey{"caller-client": caller.PublicKey()},
+	})
+	if err != nil {
+		t.Fatalf("NewPeerAssertionValidator() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		config     Config
+		forward    bool
+		wantCode   int
+		wantCaller string
+	}{
+		// デフォルトの設定ではピア自身のトークンしか受け付けない
+		{name: "own token only", config: Config{GetAccessToken: peer.GetAccessToken}, wantCode: http.StatusUnauthorized},
+		{name: "peer assertion", config: Config{TokenValidator: assertions}, wantCode: http.StatusOK, wantCaller: "caller-client"},
+		// Workerのトークンはピアアサーションとしては受け付けない
+		{name: "forwarded token to peer assertion", config: Config{TokenValidator: assertions}, forward: true, wantCode: http.StatusUnauthorized},
+		{name: "forwarded token to introspection", config: Config{TokenValidator: introspection}, forward: true, wantCode: http.StatusOK, wantCaller: "caller-client"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got TokenInfo
+			service := httptest.NewServer(NewTunnelAuthMiddleware(tt.config).Middleware(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					got, _ = TokenInfoFromContext(r.Context())
+				})))
+			defer service.Close()
+
+			if _, err := peer.RegisterTunnel(service.URL); err != nil {
+				t.Fatalf("RegisterTunnel() error = %v", err)
+			}
+			resolver := authclient.NewPeerResolver(caller, authclient.PeerResolverConfig{ForwardAccessToken: tt.forward})
+			verifies := server.Requests("/verify")
+
+			for range 2 {
+				resp, err := resolver.HTTPClient("server-client").Get("/api/test")
+				if err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != tt.wantCode {
+					t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantCode)
+				}
+			}
+			if got.ClientID != tt.wantCaller {
+				t.Errorf("TokenInfoFromContext() = %+v, want %q", got, tt.wantCaller)
+			}
+
+			// ピアの401で呼び出し元のトークンは入れ替わらない
+			if n := server.Requests("/verify"); n != verifies {
+				t.Errorf("verify requests = %d, want %d", n, verifies)
+			}
+			if caller.GetAccessToken() != callerToken || server.AccessToken("caller-client") != callerToken {
+				t.Error("caller access token was rotated")
+			}
+		})
+	}
+}
+
+func TestNewIntrospectionValidator_RequiresClient(t *testing.T) {
+	if _, err := NewIntrospectionValidator(IntrospectionConfig{}); err == nil {
+		t.Error("NewIntrospectionValidator() without client should fail")
+	}
+}
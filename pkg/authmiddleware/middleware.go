@@ -1,15 +1,66 @@
 package authmiddleware
 
 import (
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/metrics"
+)
+
+// 許可理由（メトリクスのreasonラベルに使用されます）
+const (
+	// AllowReasonPreflight はCORSプリフライトリクエスト
+	AllowReasonPreflight = "preflight"
+
+	// AllowReasonWhitelist はホワイトリストパス
+	AllowReasonWhitelist = "whitelist"
+
+	// AllowReasonLocalhost はlocalhostからのリクエスト
+	AllowReasonLocalhost = "localhost"
+
+	// AllowReasonToken はアクセストークン一致
+	AllowReasonToken = "token"
+)
+
+// 拒否理由（ログに出力されます）
+const (
+	// DenyReasonNotFromTunnel はCloudflare Tunnel以外からのリクエスト
+	DenyReasonNotFromTunnel = "not_from_tunnel"
+
+	// DenyReasonMissingHeader はAuthorizationヘッダーなし
+	DenyReasonMissingHeader = "missing_header"
+
+	// DenyReasonInvalidHeader はAuthorizationヘッダーの形式不正
+	DenyReasonInvalidHeader = "invalid_header"
+
+	// DenyReasonNotInitialized はサーバー側のアクセストークン未取得
+	DenyReasonNotInitialized = "not_initialized"
+
+	// DenyReasonBadToken はアクセストークン不一致
+	DenyReasonBadToken = "bad_token"
+
+	// DenyReasonValidatorError はTokenValidatorがトークンを検証できなかった場合
+	DenyReasonValidatorError = "validator_error"
 )
 
 // Config はミドルウェアの設定
 type Config struct {
 	// GetAccessToken は現在のアクセストークンを取得する関数
+	// TokenValidatorが指定されていない場合に使用します
 	GetAccessToken func() string
 
+	// TokenGracePeriod はアクセストークンの変更後、直前のトークンを受け付ける期間（デフォルト: 0）
+	// TokenValidatorが指定されていない場合に使用します
+	TokenGracePeriod time.Duration
+
+	// TokenValidator はBearerトークンの検証方法（オプション）
+	// nilの場合はGetAccessTokenとTokenGracePeriodからRotatingValidatorを作成します
+	TokenValidator TokenValidator
+
 	// WhitelistPaths は認証をスキップするパスのリスト
 	WhitelistPaths []string
 
@@ -19,17 +70,40 @@ type Config struct {
 	// SkipAuthForLocalhost がtrueの場合、localhostからのリクエストは認証をスキップ
 	// ローカル開発環境で使用
 	SkipAuthForLocalhost bool
+
+	// Logger は拒否理由などの構造化ログの出力先（オプション、nilの場合は出力しない）
+	// トークンの値はログに出力されません
+	Logger *slog.Logger
+
+	// Metrics はメトリクスの登録先（オプション、nilの場合は記録しない）
+	// authmiddleware_requests_total{decision,reason} に許可・拒否の件数を記録します
+	Metrics *metrics.Registry
 }
 
 // TunnelAuthMiddleware はCloudflare Tunnel経由のBearer認証ミドルウェア
 type TunnelAuthMiddleware struct {
-	config Config
+	config    Config
+	validator TokenValidator
+	requests  *metrics.Counter
 }
 
 // NewTunnelAuthMiddleware は新しいミドルウェアを作成します
 func NewTunnelAuthMiddleware(config Config) *TunnelAuthMiddleware {
+	if config.Logger == nil {
+		config.Logger = slog.New(slog.DiscardHandler)
+	}
+
+	validator := config.TokenValidator
+	if validator == nil {
+		validator = NewRotatingValidator(config.GetAccessToken, config.TokenGracePeriod)
+	}
+
 	return &TunnelAuthMiddleware{
-		config: config,
+		config:    config,
+		validator: validator,
+		requests: config.Metrics.Counter("authmiddleware_requests_total",
+			"Requests handled by the tunnel auth middleware by decision and reason.",
+			"decision", "reason"),
 	}
 }
 
@@ -38,61 +112,84 @@ func (m *TunnelAuthMiddleware) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// CORSプリフライトリクエスト（OPTIONS）は認証をスキップ
 		if r.Method == http.MethodOptions {
-			next.ServeHTTP(w, r)
+			m.allow(w, r, next, AllowReasonPreflight)
 			return
 		}
 
 		// ホワイトリストパスのチェック
 		if m.isWhitelisted(r.URL.Path) {
-			next.ServeHTTP(w, r)
+			m.allow(w, r, next, AllowReasonWhitelist)
 			return
 		}
 
 		// localhostからのリクエストは認証をスキップ
 		if m.config.SkipAuthForLocalhost && m.isLocalhost(r) {
-			next.ServeHTTP(w, r)
+			m.allow(w, r, next, AllowReasonLocalhost)
 			return
 		}
 
 		// Cloudflare Tunnel判定
 		if m.config.RequireTunnel && !m.isFromCloudflare(r) {
-			http.Error(w, "Access denied: not from Cloudflare Tunnel", http.StatusForbidden)
+			m.deny(w, r, http.StatusForbidden, DenyReasonNotFromTunnel, "Access denied: not from Cloudflare Tunnel")
 			return
 		}
 
 		// Bearer トークン認証
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+			m.deny(w, r, http.StatusUnauthorized, DenyReasonMissingHeader, "Authorization header required")
 			return
 		}
 
 		// Bearer トークンの抽出
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+			m.deny(w, r, http.StatusUnauthorized, DenyReasonInvalidHeader, "Invalid authorization header format")
 			return
 		}
 
 		token := parts[1]
 
 		// トークンの検証
-		expectedToken := m.config.GetAccessToken()
-		if expectedToken == "" {
-			http.Error(w, "Server authentication not initialized", http.StatusInternalServerError)
+		info, err := m.validator.ValidateToken(r.Context(), token)
+		switch {
+		case errors.Is(err, ErrNotInitialized):
+			m.deny(w, r, http.StatusInternalServerError, DenyReasonNotInitialized, "Server authentication not initialized")
 			return
-		}
-
-		if token != expectedToken {
-			http.Error(w, "Invalid access token", http.StatusUnauthorized)
+		case errors.Is(err, ErrInvalidToken):
+			m.deny(w, r, http.StatusUnauthorized, DenyReasonBadToken, "Invalid access token")
+			return
+		case err != nil:
+			m.config.Logger.Error("token validation failed", "error", err)
+			m.deny(w, r, http.StatusServiceUnavailable, DenyReasonValidatorError, "Token validation unavailable")
 			return
 		}
 
-		// 認証成功
-		next.ServeHTTP(w, r)
+		// 認証成功（一致したトークンの情報をハンドラに渡す）
+		r = r.WithContext(context.WithValue(r.Context(), tokenInfoKey{}, info))
+		m.allow(w, r, next, AllowReasonToken)
 	})
 }
 
+// allow はリクエストを許可し、許可理由を記録します
+func (m *TunnelAuthMiddleware) allow(w http.ResponseWriter, r *http.Request, next http.Handler, reason string) {
+	m.requests.Inc("allowed", reason)
+	next.ServeHTTP(w, r)
+}
+
+// deny はリクエストを拒否し、拒否理由をログに出力します
+func (m *TunnelAuthMiddleware) deny(w http.ResponseWriter, r *http.Request, status int, reason, message string) {
+	m.requests.Inc("denied", reason)
+	m.config.Logger.Warn("request denied",
+		"reason", reason,
+		"status", status,
+		"method", r.Method,
+		"path", r.URL.Path,
+		"remote_addr", r.RemoteAddr,
+	)
+	http.Error(w, message, status)
+}
+
 // isWhitelisted はパスがホワイトリストに含まれるかチェックします
 func (m *TunnelAuthMiddleware) isWhitelisted(path string) bool {
 	for _, whitelistPath := range m.config.WhitelistPaths {
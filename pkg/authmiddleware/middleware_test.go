@@ -1,9 +1,14 @@
 package authmiddleware
 
 import (
+	"bytes"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/metrics"
 )
 
 func TestTunnelAuthMiddleware_Middleware(t *testing.T) {
@@ -378,3 +383,121 @@ func TestTunnelAuthMiddleware_isFromCloudflare(t *testing.T) {
 		})
 	}
 }
+
+func TestTunnelAuthMiddleware_LogsDenyReason(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		config     Config
+		header     string
+		wantReason string
+	}{
+		{
+			name:       "not from tunnel",
+			config:     Config{GetAccessToken: func() string { return "server-token" }, RequireTunnel: true},
+			wantReason: DenyReasonNotFromTunnel,
+		},
+		{
+			name:       "missing header",
+			config:     Config{GetAccessToken: func() string { return "server-token" }},
+			wantReason: DenyReasonMissingHeader,
+		},
+		{
+			name:       "invalid header",
+			config:     Config{GetAccessToken: func() string { return "server-token" }},
+			header:     "Basic secret-credential",
+			wantReason: DenyReasonInvalidHeader,
+		},
+		{
+			name:       "not initialized",
+			config:     Config{GetAccessToken: func() string { return "" }},
+			header:     "Bearer client-token",
+			wantReason: DenyReasonNotInitialized,
+		},
+		{
+			name:       "bad token",
+			config:     Config{GetAccessToken: func() string { return "server-token" }},
+			header:     "Bearer client-token",
+			wantReason: DenyReasonBadToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.config.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+
+			handler := NewTunnelAuthMiddleware(tt.config).Middleware(testHandler)
+
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			logged := buf.String()
+			if !strings.Contains(logged, `"reason":"`+tt.wantReason+`"`) {
+				t.Errorf("log = %s, want reason %s", logged, tt.wantReason)
+			}
+			// トークンや資格情報はログに出力されない
+			for _, secret := range []string{"server-token", "client-token", "secret-credential"} {
+				if strings.Contains(logged, secret) {
+					t.Errorf("log contains secret %q: %s", secret, logged)
+				}
+			}
+		})
+	}
+}
+
+func TestTunnelAuthMiddleware_Metrics(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	registry := metrics.NewRegistry()
+	handler := NewTunnelAuthMiddleware(Config{
+		GetAccessToken:       func() string { return "server-token" },
+		WhitelistPaths:       []string{"/health"},
+		SkipAuthForLocalhost: true,
+		Metrics:              registry,
+	}).Middleware(testHandler)
+
+	requests := []struct {
+		path       string
+		remoteAddr string
+		header     string
+	}{
+		{path: "/health", remoteAddr: "203.0.113.1:1234"},
+		{path: "/api", remoteAddr: "127.0.0.1:1234"},
+		{path: "/api", remoteAddr: "203.0.113.1:1234", header: "Bearer server-token"},
+		{path: "/api", remoteAddr: "203.0.113.1:1234", header: "Bearer client-token"},
+		{path: "/api", remoteAddr: "203.0.113.1:1234", header: "Bearer client-token"},
+		{path: "/api", remoteAddr: "203.0.113.1:1234"},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest("GET", r.path, nil)
+		req.RemoteAddr = r.remoteAddr
+		if r.header != "" {
+			req.Header.Set("Authorization", r.header)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var buf strings.Builder
+	registry.WriteText(&buf)
+
+	for _, want := range []string{
+		`authmiddleware_requests_total{decision="allowed",reason="whitelist"} 1`,
+		`authmiddleware_requests_total{decision="allowed",reason="localhost"} 1`,
+		`authmiddleware_requests_total{decision="allowed",reason="token"} 1`,
+		`authmiddleware_requests_total{decision="denied",reason="bad_token"} 2`,
+		`authmiddleware_requests_total{decision="denied",reason="missing_header"} 1`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, buf.String())
+		}
+	}
+}
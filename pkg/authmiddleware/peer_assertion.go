@@ -0,0 +1,94 @@
+package authmiddleware
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/authclient"
+)
+
+// PeerAssertionConfig はPeerAssertionValidatorの設定
+type PeerAssertionConfig struct {
+	// ClientID は自身のクライアントID（必須）
+	// この宛てに署名されたピアアサーションのみ受け付けます
+	ClientID string
+
+	// Clients は呼び出しを許可するクライアントIDと公開鍵（必須）
+	// authserver.LoadClientsで.cloudflare.jsonファイルから読み込めます
+	Clients map[string]crypto.PublicKey
+
+	// MaxLifetime は受け付けるピアアサーションの有効期間の上限（デフォルト: 5分）
+	MaxLifetime time.Duration
+
+	// ClockSkew は許容する時刻のずれ（デフォルト: 30秒）
+	ClockSkew time.Duration
+
+	// Fallback はピアアサーション以外のトークンを検証するTokenValidator（オプション）
+	// nilの場合はピアアサーション以外のトークンを拒否します
+	Fallback TokenValidator
+
+	// Logger は構造化ログの出力先（オプション、nilの場合は出力しない）
+	Logger *slog.Logger
+}
+
+// PeerAssertionValidator はPeerResolverが送信するピアアサーションを
+// 呼び出し元の公開鍵で検証するTokenValidator
+// 一致したトークンの名前は"peer"、ClientIDは呼び出し元のクライアントIDです
+type PeerAssertionValidator struct {
+	config PeerAssertionConfig
+}
+
+// NewPeerAssertionValidator は新しいPeerAssertionValidatorを作成します
+func NewPeerAssertionValidator(config PeerAssertionConfig) (*PeerAssertionValidator, error) {
+	if config.ClientID == "" {
+		return nil, errors.New("authmiddleware: peer assertion client ID is required")
+	}
+	if len(config.Clients) == 0 {
+		return nil, errors.New("authmiddleware: peer assertion clients are required")
+	}
+	if config.MaxLifetime <= 0 {
+		config.MaxLifetime = 5 * time.Minute
+	}
+	if config.ClockSkew <= 0 {
+		config.ClockSkew = 30 * time.Second
+	}
+	if config.Logger == nil {
+		config.Logger = slog.New(slog.DiscardHandler)
+	}
+
+	return &PeerAssertionValidator{config: config}, nil
+}
+
+// ValidateToken はTokenValidatorの実装です
+func (v *PeerAssertionValidator) ValidateToken(ctx context.Context, token string) (TokenInfo, error) {
+	if !strings.HasPrefix(token, authclient.PeerAssertionPrefix) {
+		if v.config.Fallback == nil {
+			return TokenInfo{}, ErrInvalidToken
+		}
+		return v.config.Fallback.ValidateToken(ctx, token)
+	}
+
+	assertion, err := authclient.ParsePeerAssertion(token)
+	if err != nil {
+		v.config.Logger.Info("peer assertion rejected", "error", err)
+		return TokenInfo{}, ErrInvalidToken
+	}
+
+	publicKey, ok := v.config.Clients[assertion.Issuer]
+	if !ok {
+		v.config.Logger.Info("peer assertion from unknown client rejected", "peer_id", assertion.Issuer)
+		return TokenInfo{}, ErrInvalidToken
+	}
+
+	err = authclient.VerifyPeerAssertion(assertion, publicKey, v.config.ClientID, v.config.MaxLifetime, v.config.ClockSkew)
+	if err != nil {
+		v.config.Logger.Info("peer assertion rejected", "peer_id", assertion.Issuer, "error", err)
+		return TokenInfo{}, ErrInvalidToken
+	}
+
+	return TokenInfo{Name: "peer", ClientID: assertion.Issuer}, nil
+}
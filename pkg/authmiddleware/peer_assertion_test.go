@@ -0,0 +1,75 @@
+package authmiddleware
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/authclient"
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/authtest"
+)
+
+func TestPeerAssertionValidator(t *testing.T) {
+	server := authtest.NewServer(authtest.Config{})
+	t.Cleanup(server.Close)
+
+	caller := newTestClient(t, server, "caller-client")
+	stranger := newTestClient(t, server, "stranger-client")
+	ctx := context.Background()
+
+	validator, err := NewPeerAssertionValidator(PeerAssertionConfig{
+		ClientID: "server-client",
+		Clients:  map[string]crypto.PublicKey{"caller-client": caller.PublicKey()},
+		Fallback: TokenList(func() []NamedToken { return []NamedToken{{Name: "own", Token: "own-token"}} }),
+	})
+	if err != nil {
+		t.Fatalf("NewPeerAssertionValidator() error = %v", err)
+	}
+
+	assertion := func(client *authclient.Client, peerID string, ttl time.Duration) string {
+		t.Helper()
+		value, err := client.NewPeerAssertion(peerID, ttl)
+		if err != nil {
+			t.Fatalf("NewPeerAssertion() error = %v", err)
+		}
+		return value
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		wantInfo TokenInfo
+		wantErr  error
+	}{
+		{name: "valid", token: assertion(caller, "server-client", time.Minute), wantInfo: TokenInfo{Name: "peer", ClientID: "caller-client"}},
+		{name: "other audience", token: assertion(caller, "other-client", time.Minute), wantErr: ErrInvalidToken},
+		{name: "unknown client", token: assertion(stranger, "server-client", time.Minute), wantErr: ErrInvalidToken},
+		{name: "lifetime too long", token: assertion(caller, "server-client", time.Hour), wantErr: ErrInvalidToken},
+		{name: "malformed", token: "peer.invalid", wantErr: ErrInvalidToken},
+		{name: "fallback", token: "own-token", wantInfo: TokenInfo{Name: "own"}},
+		{name: "fallback mismatch", token: "other-token", wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := validator.ValidateToken(ctx, tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if info != tt.wantInfo {
+				t.Errorf("ValidateToken() = %+v, want %+v", info, tt.wantInfo)
+			}
+		})
+	}
+}
+
+func TestNewPeerAssertionValidator_RequiresConfig(t *testing.T) {
+	if _, err := NewPeerAssertionValidator(PeerAssertionConfig{ClientID: "server-client"}); err == nil {
+		t.Error("NewPeerAssertionValidator() without clients should fail")
+	}
+	if _, err := NewPeerAssertionValidator(PeerAssertionConfig{Clients: map[string]crypto.PublicKey{"a": nil}}); err == nil {
+		t.Error("NewPeerAssertionValidator() without client ID should fail")
+	}
+}
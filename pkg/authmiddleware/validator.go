@@ -0,0 +1,144 @@
+package authmiddleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidToken はトークンが有効なトークンのいずれにも一致しない場合のエラー
+	ErrInvalidToken = errors.New("invalid access token")
+
+	// ErrNotInitialized は照合できる有効なトークンが1つもない場合のエラー
+	ErrNotInitialized = errors.New("no valid access token configured")
+)
+
+// TokenValidator はBearerトークンを検証します
+type TokenValidator interface {
+	// ValidateToken はトークンを検証し、一致したトークンの情報を返します
+	// 一致しない場合はErrInvalidToken、有効なトークンがない場合はErrNotInitializedを返します
+	// それ以外のエラーは検証できなかったものとして503を返します
+	ValidateToken(ctx context.Context, token string) (TokenInfo, error)
+}
+
+// TokenInfo は検証に成功したトークンの情報
+type TokenInfo struct {
+	// Name は一致したトークンの名前（例: "current"、"previous"）
+	Name string
+
+	// ClientID はトークンを発行されたクライアントID（分かる場合のみ）
+	ClientID string
+}
+
+// tokenInfoKey はcontextにTokenInfoを格納するキー
+type tokenInfoKey struct{}
+
+// TokenInfoFromContext はミドルウェアがトークン認証で許可したリクエストのTokenInfoを返します
+func TokenInfoFromContext(ctx context.Context) (TokenInfo, bool) {
+	info, ok := ctx.Value(tokenInfoKey{}).(TokenInfo)
+	return info, ok
+}
+
+// NamedToken は名前付きの有効なトークン
+type NamedToken struct {
+	Name  string
+	Token string
+}
+
+// TokenList は現在有効なトークンの一覧を返す関数をTokenValidatorとして扱います
+// 照合は全てのトークンに対して定数時間で行い、一致したトークンの名前を返します
+type TokenList func() []NamedToken
+
+// ValidateToken はTokenValidatorの実装です
+func (f TokenList) ValidateToken(ctx context.Context, token string) (TokenInfo, error) {
+	return matchToken(f(), token)
+}
+
+// RotatingValidator は現在のアクセストークンに加え、
+// 直前のトークンを変更後GracePeriodの間受け付けるTokenValidator
+// クライアントの再認証中に送信されたリクエストが拒否されるのを防ぎます
+type RotatingValidator struct {
+	getAccessToken func() string
+	gracePeriod    time.Duration
+
+	mu            sync.Mutex
+	current       string
+	previous      string
+	previousUntil time.Time
+}
+
+// NewRotatingValidator は新しいRotatingValidatorを作成します
+// gracePeriodが0の場合は現在のトークンのみを受け付けます
+func NewRotatingValidator(getAccessToken func() string, gracePeriod time.Duration) *RotatingValidator {
+	return &RotatingValidator{
+		getAccessToken: getAccessToken,
+		gracePeriod:    gracePeriod,
+	}
+}
+
+// ValidateToken はTokenValidatorの実装です
+// 一致したトークンの名前は"current"または"previous"です
+func (v *RotatingValidator) ValidateToken(ctx context.Context, token string) (TokenInfo, error) {
+	return matchToken(v.Tokens(), token)
+}
+
+// Tokens は現在受け付けているトークンを返します
+func (v *RotatingValidator) Tokens() []NamedToken {
+	var current string
+	if v.getAccessToken != nil {
+		current = v.getAccessToken()
+	}
+	now := time.Now()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// トークンの変更を検出したら直前のトークンを猶予期間付きで保持する
+	if current != v.current {
+		if v.current != "" && v.gracePeriod > 0 {
+			v.previous = v.current
+			v.previousUntil = now.Add(v.gracePeriod)
+		}
+		v.current = current
+	}
+
+	tokens := []NamedToken{{Name: "current", Token: current}}
+	if v.previous != "" && now.Before(v.previousUntil) {
+		tokens = append(tokens, NamedToken{Name: "previous", Token: v.previous})
+	}
+	return tokens
+}
+
+// matchToken は全ての有効なトークンと定数時間で照合します
+// 長さの違いが処理時間に現れないよう、ハッシュ値同士を比較します
+func matchToken(tokens []NamedToken, token string) (TokenInfo, error) {
+	presented := sha256.Sum256([]byte(token))
+
+	matched := -1
+	configured := false
+	for i, candidate := range tokens {
+		if candidate.Token == "" {
+			continue
+		}
+		configured = true
+
+		expected := sha256.Sum256([]byte(candidate.Token))
+		// 一致しても打ち切らず、全てのトークンと比較する
+		if subtle.ConstantTimeCompare(presented[:], expected[:]) == 1 && matched < 0 {
+			matched = i
+		}
+	}
+
+	switch {
+	case !configured:
+		return TokenInfo{}, ErrNotInitialized
+	case matched < 0 || token == "":
+		return TokenInfo{}, ErrInvalidToken
+	default:
+		return TokenInfo{Name: tokens[matched].Name}, nil
+	}
+}
@@ -0,0 +1,127 @@
+package authmiddleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMatchToken(t *testing.T) {
+	tokens := []NamedToken{
+		{Name: "current", Token: "token-b"},
+		{Name: "previous", Token: "token-a"},
+		{Name: "unset", Token: ""},
+	}
+
+	tests := []struct {
+		name     string
+		tokens   []NamedToken
+		token    string
+		wantName string
+		wantErr  error
+	}{
+		{name: "current", tokens: tokens, token: "token-b", wantName: "current"},
+		{name: "previous", tokens: tokens, token: "token-a", wantName: "previous"},
+		{name: "mismatch", tokens: tokens, token: "token-c", wantErr: ErrInvalidToken},
+		{name: "prefix", tokens: tokens, token: "token", wantErr: ErrInvalidToken},
+		{name: "empty", tokens: tokens, token: "", wantErr: ErrInvalidToken},
+		{name: "no tokens", tokens: []NamedToken{{Name: "current"}}, token: "token-a", wantErr: ErrNotInitialized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := TokenList(func() []NamedToken { return tt.tokens }).ValidateToken(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if info.Name != tt.wantName {
+				t.Errorf("ValidateToken() name = %q, want %q", info.Name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestRotatingValidator(t *testing.T) {
+	var current atomic.Value
+	current.Store("token-a")
+	getAccessToken := func() string { return current.Load().(string) }
+	ctx := context.Background()
+
+	t.Run("grace period", func(t *testing.T) {
+		current.Store("token-a")
+		v := NewRotatingValidator(getAccessToken, 100*time.Millisecond)
+
+		if info, err := v.ValidateToken(ctx, "token-a"); err != nil || info.Name != "current" {
+			t.Fatalf("ValidateToken(token-a) = %+v, %v", info, err)
+		}
+
+		current.Store("token-b")
+		if info, err := v.ValidateToken(ctx, "token-b"); err != nil || info.Name != "current" {
+			t.Errorf("ValidateToken(token-b) = %+v, %v", info, err)
+		}
+		if info, err := v.ValidateToken(ctx, "token-a"); err != nil || info.Name != "previous" {
+			t.Errorf("ValidateToken(token-a) during grace = %+v, %v", info, err)
+		}
+
+		time.Sleep(150 * time.Millisecond)
+		if _, err := v.ValidateToken(ctx, "token-a"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("ValidateToken(token-a) after grace error = %v, want ErrInvalidToken", err)
+		}
+	})
+
+	t.Run("no grace period", func(t *testing.T) {
+		current.Store("token-a")
+		v := NewRotatingValidator(getAccessToken, 0)
+		v.ValidateToken(ctx, "token-a")
+
+		current.Store("token-b")
+		if _, err := v.ValidateToken(ctx, "token-a"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("ValidateToken(token-a) error = %v, want ErrInvalidToken", err)
+		}
+	})
+}
+
+// failingValidator は常にエラーを返すTokenValidator
+type failingValidator struct{}
+
+func (failingValidator) ValidateToken(ctx context.Context, token string) (TokenInfo, error) {
+	return TokenInfo{}, errors.New("validator unavailable")
+}
+
+func TestTunnelAuthMiddleware_TokenValidator(t *testing.T) {
+	var got TokenInfo
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = TokenInfoFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func(config Config, token string) int {
+		req := httptest.NewRequest("GET", "/api/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		NewTunnelAuthMiddleware(config).Middleware(testHandler).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tokens := TokenList(func() []NamedToken {
+		return []NamedToken{{Name: "primary", Token: "token-a"}, {Name: "secondary", Token: "token-b"}}
+	})
+
+	if code := serve(Config{TokenValidator: tokens}, "token-b"); code != http.StatusOK {
+		t.Errorf("status = %d, want 200", code)
+	}
+	if got.Name != "secondary" {
+		t.Errorf("TokenInfoFromContext() = %+v, want secondary", got)
+	}
+
+	if code := serve(Config{TokenValidator: tokens}, "token-c"); code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", code)
+	}
+	if code := serve(Config{TokenValidator: failingValidator{}}, "token-a"); code != http.StatusServiceUnavailable {
+		t.Errorf("status with failing validator = %d, want 503", code)
+	}
+}
@@ -0,0 +1,49 @@
+package authserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/keygen"
+)
+
+// ParseClients はWorkerのAUTHORIZED_CLIENTSと同じ形式
+// （{"clientId": "PEM形式の公開鍵"}）のJSONからクライアントを読み込みます
+func ParseClients(data []byte) (StaticClients, error) {
+	var entries map[string]string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse clients: %w", err)
+	}
+
+	clients := make(StaticClients, len(entries))
+	for clientID, publicKeyPEM := range entries {
+		publicKey, err := keygen.ParsePKIXPublicKeyPEM([]byte(publicKeyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key for client %s: %w", clientID, err)
+		}
+		clients[clientID] = publicKey
+	}
+	return clients, nil
+}
+
+// LoadClients はkeygen.SaveCloudflareConfigが書き出す.cloudflare.jsonファイルを読み込みます
+// 複数のファイルを指定した場合は1つのレジストリにまとめます（同じclientIDは後のファイルが優先）
+func LoadClients(filenames ...string) (StaticClients, error) {
+	clients := make(StaticClients)
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read clients file: %w", err)
+		}
+
+		parsed, err := ParseClients(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		for clientID, publicKey := range parsed {
+			clients[clientID] = publicKey
+		}
+	}
+	return clients, nil
+}
@@ -0,0 +1,573 @@
+// Package authserver はCloudflare Auth Workerと同じプロトコルを話すhttp.Handlerを提供します
+//
+// オンプレミスや外部に接続できない環境で、authclientとauthmiddlewareをそのまま使うために使用します
+// チャレンジ・クライアント・Secret変数・アクセストークン・トンネルの保存先は差し替え可能です
+//
+//	clients, err := authserver.LoadClients("public_key.pem.cloudflare.json")
+//	server, err := authserver.NewServer(authserver.Config{
+//	    Clients: clients,
+//	    Secrets: authserver.StaticSecrets{"my-client": {"API_KEY": "..."}},
+//	})
+//	http.ListenAndServe(":8787", server)
+package authserver
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	internalcrypto "github.com/yhonda-ohishi-pub-dev/go_auth/internal/crypto"
+)
+
+// maxRequestBytes はリクエストボディの最大サイズ
+const maxRequestBytes = 64 << 10
+
+// maxAuthRequestBytes は認証前に受け付ける/challengeと/verifyのリクエストボディの最大サイズ
+const maxAuthRequestBytes = 8 << 10
+
+// Config はサーバーの設定
+type Config struct {
+	// Clients はクライアントの公開鍵の取得先（必須）
+	Clients ClientRegistry
+
+	// Secrets はSecret変数の取得先（オプション、nilの場合は空のSecret変数を返す）
+	Secrets SecretStore
+
+	// RepoLists はincludeRepoList=trueの場合に返すリポジトリURLのリストの取得先
+	// （オプション、nilの場合はリストを返さない）
+	RepoLists RepoListStore
+
+	// Challenges はチャレンジの保存先（デフォルト: メモリ）
+	Challenges ChallengeStore
+
+	// Tokens はアクセストークンの保存先（デフォルト: メモリ）
+	Tokens TokenStore
+
+	// Tunnels はトンネル情報の保存先（デフォルト: メモリ）
+	Tunnels TunnelStore
+
+	// ChallengeTTL はチャレンジの有効期間（デフォルト: 60秒）
+	ChallengeTTL time.Duration
+
+	// TokenTTL はアクセストークンの有効期間（デフォルト: 1時間）
+	TokenTTL time.Duration
+
+	// TunnelListClients はGET /tunnelsで一覧の取得を許可するクライアント（それ以外は403）
+	TunnelListClients []string
+
+	// RestrictTunnelLookup がtrueの場合、GET /tunnel/{clientId}で他のクライアントの参照を403にします
+	RestrictTunnelLookup bool
+
+	// Logger は構造化ログの出力先（オプション、nilの場合は出力しない）
+	// トークン・署名・Secret値はログに出力されません
+	Logger *slog.Logger
+}
+
+// Server はCloudflare Auth Workerと同じプロトコルを提供するhttp.Handler
+type Server struct {
+	clients      ClientRegistry
+	secrets      SecretStore
+	repoLists    RepoListStore
+	challenges   ChallengeStore
+	tokens       TokenStore
+	tunnels      TunnelStore
+	challengeTTL time.Duration
+	tokenTTL     time.Duration
+	listClients  []string
+	restrict     bool
+	logger       *slog.Logger
+	mux          *http.ServeMux
+}
+
+// NewServer は新しいサーバーを作成します
+func NewServer(config Config) (*Server, error) {
+	if config.Clients == nil {
+		return nil, errors.New("authserver: clients registry is required")
+	}
+
+	s := &Server{
+		clients:      config.Clients,
+		secrets:      config.Secrets,
+		repoLists:    config.RepoLists,
+		challenges:   config.Challenges,
+		tokens:       config.Tokens,
+		tunnels:      config.Tunnels,
+		challengeTTL: config.ChallengeTTL,
+		tokenTTL:     config.TokenTTL,
+		listClients:  slices.Clone(config.TunnelListClients),
+		restrict:     config.RestrictTunnelLookup,
+		logger:       config.Logger,
+	}
+	if s.secrets == nil {
+		s.secrets = StaticSecrets{}
+	}
+	if s.challenges == nil {
+		s.challenges = NewMemoryChallengeStore()
+	}
+	if s.tokens == nil {
+		s.tokens = NewMemoryTokenStore()
+	}
+	if s.tunnels == nil {
+		s.tunnels = NewMemoryTunnelStore()
+	}
+	if s.challengeTTL <= 0 {
+		s.challengeTTL = 60 * time.Second
+	}
+	if s.tokenTTL <= 0 {
+		s.tokenTTL = time.Hour
+	}
+	if s.logger == nil {
+		s.logger = slog.New(slog.DiscardHandler)
+	}
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("POST /challenge", s.handleChallenge)
+	s.mux.HandleFunc("POST /verify", s.handleVerify)
+	s.mux.HandleFunc("GET /health", s.handleHealth)
+	s.mux.HandleFunc("POST /tunnel/register", s.handleTunnelRegister)
+	s.mux.HandleFunc("GET /tunnel/{clientId}", s.handleTunnelGet)
+	s.mux.HandleFunc("DELETE /tunnel/{clientId}", s.handleTunnelDelete)
+	s.mux.HandleFunc("GET /tunnels", s.handleTunnelList)
+	s.mux.HandleFunc("POST /introspect", s.handleIntrospect)
+
+	return s, nil
+}
+
+// ServeHTTP はリクエストを処理します
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+	s.mux.ServeHTTP(w, r)
+}
+
+// challengeRequest は/challengeのリクエスト
+type challengeRequest struct {
+	ClientID string `json:"clientId"`
+}
+
+// challengeResponse は/challengeのレスポンス
+type challengeResponse struct {
+	Challenge string `json:"challenge"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// verifyRequest は/verifyのリクエスト
+type verifyRequest struct {
+	ClientID        string `json:"clientId"`
+	Challenge       string `json:"challenge"`
+	Signature       string `json:"signature"`
+	Algorithm       string `json:"algorithm,omitempty"`
+	RepoUrl         string `json:"repoUrl,omitempty"`
+	GrpcEndpoint    string `json:"grpcEndpoint,omitempty"`
+	IncludeRepoList bool   `json:"includeRepoList,omitempty"`
+	TunnelUrl       string `json:"tunnelUrl,omitempty"`
+}
+
+// verifyResponse は/verifyの成功レスポンス
+type verifyResponse struct {
+	Success              bool              `json:"success"`
+	Token                string            `json:"token"`
+	AccessToken          string            `json:"accessToken"`
+	AccessTokenExpiresAt int64             `json:"accessTokenExpiresAt"`
+	SecretData           map[string]string `json:"secretData"`
+	RepoList             []string          `json:"repoList,omitempty"`
+}
+
+// tunnelRegisterRequest は/tunnel/registerのリクエスト
+type tunnelRegisterRequest struct {
+	ClientID  string `json:"clientId"`
+	TunnelUrl string `json:"tunnelUrl"`
+	Token     string `json:"token"`
+}
+
+// tunnelData はトンネル情報のレスポンス形式
+type tunnelData struct {
+	ClientID  string `json:"clientId"`
+	TunnelUrl string `json:"tunnelUrl"`
+	Token     string `json:"token,omitempty"`
+	UpdatedAt int64  `json:"updatedAt"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// tunnelResponse はトンネル登録・取得・削除のレスポンス
+type tunnelResponse struct {
+	Success bool       `json:"success"`
+	Data    tunnelData `json:"data"`
+}
+
+// tunnelListResponse はトンネル一覧のレスポンス
+type tunnelListResponse struct {
+	Success bool         `json:"success"`
+	Data    []tunnelData `json:"data"`
+}
+
+// introspectRequest は/introspectのリクエスト
+type introspectRequest struct {
+	Token string `json:"token"`
+}
+
+// introspectResponse は/introspectのレスポンス
+type introspectResponse struct {
+	Success   bool   `json:"success"`
+	Active    bool   `json:"active"`
+	ClientID  string `json:"clientId,omitempty"`
+	ExpiresAt int64  `json:"expiresAt,omitempty"`
+}
+
+func (s *Server) handleChallenge(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAuthRequestBytes)
+
+	var req challengeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ClientID == "" {
+		writeError(w, http.StatusBadRequest, "Missing clientId")
+		return
+	}
+
+	ctx := r.Context()
+	if _, err := s.clients.PublicKey(ctx, req.ClientID); err != nil {
+		s.clientError(w, req.ClientID, err)
+		return
+	}
+
+	challenge, err := randomToken()
+	if err != nil {
+		s.internalError(w, "failed to generate challenge", err)
+		return
+	}
+
+	expiresAt := time.Now().Add(s.challengeTTL)
+	if err := s.challenges.Put(ctx, challenge, Challenge{ClientID: req.ClientID, ExpiresAt: expiresAt}); err != nil {
+		if errors.Is(err, ErrTooManyChallenges) {
+			s.logger.Warn("request rejected", "client_id", req.ClientID, "reason", "too_many_challenges")
+			writeError(w, http.StatusTooManyRequests, "Too many pending challenges")
+			return
+		}
+		s.internalError(w, "failed to store challenge", err)
+		return
+	}
+
+	s.logger.Debug("challenge issued", "client_id", req.ClientID, "expires_at", expiresAt)
+	writeJSON(w, challengeResponse{Challenge: challenge, ExpiresAt: expiresAt.Unix()})
+}
+
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAuthRequestBytes)
+
+	var req verifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if req.ClientID == "" || req.Challenge == "" || req.Signature == "" {
+		writeError(w, http.StatusBadRequest, "Missing required fields")
+		return
+	}
+
+	ctx := r.Context()
+	publicKey, err := s.clients.PublicKey(ctx, req.ClientID)
+	if err != nil {
+		s.clientError(w, req.ClientID, err)
+		return
+	}
+
+	// チャレンジは使い捨て（検証の成否に関わらず削除）
+	challenge, ok, err := s.challenges.Take(ctx, req.Challenge)
+	if err != nil {
+		s.internalError(w, "failed to load challenge", err)
+		return
+	}
+	if !ok || challenge.ClientID != req.ClientID {
+		s.reject(w, req.ClientID, "unknown_challenge", "Invalid or already used challenge")
+		return
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		s.reject(w, req.ClientID, "challenge_expired", "Challenge expired")
+		return
+	}
+
+	// アルゴリズム未指定は従来のRS256として扱う
+	alg := internalcrypto.Algorithm(req.Algorithm)
+	if alg == "" {
+		alg = internalcrypto.AlgorithmRS256
+	}
+	if err := internalcrypto.VerifySignatureWithAlgorithm(publicKey, alg, req.Challenge, req.Signature); err != nil {
+		s.reject(w, req.ClientID, "invalid_signature", "Invalid signature")
+		return
+	}
+
+	secrets, err := s.secrets.Secrets(ctx, req.ClientID)
+	if err != nil {
+		s.internalError(w, "failed to load secrets", err)
+		return
+	}
+
+	var repoList []string
+	if req.IncludeRepoList && s.repoLists != nil {
+		repoList, err = s.repoLists.RepoList(ctx, req.ClientID)
+		if err != nil {
+			s.internalError(w, "failed to load repository list", err)
+			return
+		}
+	}
+
+	expiresAt := time.Now().Add(s.tokenTTL)
+	accessToken, err := s.tokens.Issue(ctx, req.ClientID, expiresAt)
+	if err != nil {
+		s.internalError(w, "failed to issue access token", err)
+		return
+	}
+
+	// トンネルURLが指定されている場合は新しいトークンで登録を更新
+	if req.TunnelUrl != "" {
+		if _, err := s.tunnels.Save(ctx, req.ClientID, req.TunnelUrl, accessToken); err != nil {
+			s.internalError(w, "failed to save tunnel", err)
+			return
+		}
+	}
+
+	sessionToken, err := randomToken()
+	if err != nil {
+		s.internalError(w, "failed to generate token", err)
+		return
+	}
+
+	s.logger.Info("client verified", "client_id", req.ClientID, "algorithm", string(alg), "secret_count", len(secrets))
+	writeJSON(w, verifyResponse{
+		Success:              true,
+		Token:                sessionToken,
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: expiresAt.Unix(),
+		SecretData:           secrets,
+		RepoList:             repoList,
+	})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleTunnelRegister(w http.ResponseWriter, r *http.Request) {
+	var req tunnelRegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if req.ClientID == "" || req.TunnelUrl == "" || req.Token == "" {
+		writeError(w, http.StatusBadRequest, "Missing required fields")
+		return
+	}
+
+	ctx := r.Context()
+	token, ok, err := s.tokens.Lookup(ctx, req.Token)
+	if err != nil {
+		s.internalError(w, "failed to look up token", err)
+		return
+	}
+	if !ok || token.ClientID != req.ClientID {
+		s.reject(w, req.ClientID, "bad_token", "Invalid token")
+		return
+	}
+
+	tunnel, err := s.tunnels.Save(ctx, req.ClientID, req.TunnelUrl, req.Token)
+	if err != nil {
+		s.internalError(w, "failed to save tunnel", err)
+		return
+	}
+
+	s.logger.Info("tunnel registered", "client_id", req.ClientID, "tunnel_url", req.TunnelUrl)
+	writeJSON(w, tunnelResponse{Success: true, Data: toTunnelData(tunnel, true)})
+}
+
+func (s *Server) handleTunnelGet(w http.ResponseWriter, r *http.Request) {
+	caller, ok, err := s.bearer(r)
+	if err != nil {
+		s.internalError(w, "failed to look up token", err)
+		return
+	}
+	if !ok {
+		s.reject(w, "", "bad_token", "Invalid token")
+		return
+	}
+
+	clientID := r.PathValue("clientId")
+	if s.restrict && caller.ClientID != clientID {
+		s.forbid(w, caller.ClientID, "tunnel_lookup", "Tunnel lookup not allowed")
+		return
+	}
+
+	tunnel, err := s.tunnels.Get(r.Context(), clientID)
+	if errors.Is(err, ErrTunnelNotFound) {
+		writeError(w, http.StatusNotFound, "Tunnel not found")
+		return
+	}
+	if err != nil {
+		s.internalError(w, "failed to load tunnel", err)
+		return
+	}
+
+	// 他のクライアントのトンネルにはアクセストークンを含めない
+	writeJSON(w, tunnelResponse{Success: true, Data: toTunnelData(tunnel, caller.ClientID == clientID)})
+}
+
+func (s *Server) handleTunnelDelete(w http.ResponseWriter, r *http.Request) {
+	caller, ok, err := s.bearer(r)
+	if err != nil {
+		s.internalError(w, "failed to look up token", err)
+		return
+	}
+	if !ok {
+		s.reject(w, "", "bad_token", "Invalid token")
+		return
+	}
+
+	// 自身のトンネルのみ削除できる
+	clientID := r.PathValue("clientId")
+	if caller.ClientID != clientID {
+		s.forbid(w, caller.ClientID, "tunnel_delete", "Cannot unregister another client's tunnel")
+		return
+	}
+
+	tunnel, err := s.tunnels.Delete(r.Context(), clientID)
+	if errors.Is(err, ErrTunnelNotFound) {
+		writeError(w, http.StatusNotFound, "Tunnel not found")
+		return
+	}
+	if err != nil {
+		s.internalError(w, "failed to delete tunnel", err)
+		return
+	}
+
+	s.logger.Info("tunnel unregistered", "client_id", clientID)
+	writeJSON(w, tunnelResponse{Success: true, Data: toTunnelData(tunnel, true)})
+}
+
+func (s *Server) handleTunnelList(w http.ResponseWriter, r *http.Request) {
+	caller, ok, err := s.bearer(r)
+	if err != nil {
+		s.internalError(w, "failed to look up token", err)
+		return
+	}
+	if !ok {
+		s.reject(w, "", "bad_token", "Invalid token")
+		return
+	}
+	if !slices.Contains(s.listClients, caller.ClientID) {
+		s.forbid(w, caller.ClientID, "tunnel_list", "Tunnel list not allowed")
+		return
+	}
+
+	tunnels, err := s.tunnels.List(r.Context())
+	if err != nil {
+		s.internalError(w, "failed to list tunnels", err)
+		return
+	}
+
+	data := make([]tunnelData, 0, len(tunnels))
+	for _, tunnel := range tunnels {
+		// 一覧にはアクセストークンを含めない
+		data = append(data, toTunnelData(tunnel, false))
+	}
+	writeJSON(w, tunnelListResponse{Success: true, Data: data})
+}
+
+func (s *Server) handleIntrospect(w http.ResponseWriter, r *http.Request) {
+	_, ok, err := s.bearer(r)
+	if err != nil {
+		s.internalError(w, "failed to look up token", err)
+		return
+	}
+	if !ok {
+		s.reject(w, "", "bad_token", "Invalid token")
+		return
+	}
+
+	var req introspectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		writeError(w, http.StatusBadRequest, "Missing token")
+		return
+	}
+
+	token, active, err := s.tokens.Lookup(r.Context(), req.Token)
+	if err != nil {
+		s.internalError(w, "failed to look up token", err)
+		return
+	}
+	if !active {
+		writeJSON(w, introspectResponse{Success: true})
+		return
+	}
+
+	writeJSON(w, introspectResponse{
+		Success:   true,
+		Active:    true,
+		ClientID:  token.ClientID,
+		ExpiresAt: token.ExpiresAt.Unix(),
+	})
+}
+
+// bearer はAuthorizationヘッダーのアクセストークンを照会します
+func (s *Server) bearer(r *http.Request) (Token, bool, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return Token{}, false, nil
+	}
+	return s.tokens.Lookup(r.Context(), token)
+}
+
+// clientError はクライアントの照会エラーを返します
+func (s *Server) clientError(w http.ResponseWriter, clientID string, err error) {
+	if errors.Is(err, ErrUnknownClient) {
+		s.reject(w, clientID, "unknown_client", "Unknown client")
+		return
+	}
+	s.internalError(w, "failed to look up client", err)
+}
+
+// reject は認証失敗（401）を返し、理由をログに出力します
+func (s *Server) reject(w http.ResponseWriter, clientID, reason, message string) {
+	s.logger.Warn("request rejected", "client_id", clientID, "reason", reason)
+	writeError(w, http.StatusUnauthorized, message)
+}
+
+// forbid は認可されていない操作（403）を返し、理由をログに出力します
+func (s *Server) forbid(w http.ResponseWriter, clientID, reason, message string) {
+	s.logger.Warn("request forbidden", "client_id", clientID, "reason", reason)
+	writeError(w, http.StatusForbidden, message)
+}
+
+// internalError はストアの障害などで500を返します（詳細はログのみに出力）
+func (s *Server) internalError(w http.ResponseWriter, message string, err error) {
+	s.logger.Error(message, "error", err)
+	writeError(w, http.StatusInternalServerError, "Internal server error")
+}
+
+// toTunnelData はトンネル情報をレスポンス形式に変換します
+// includeTokenがfalseの場合はアクセストークンを含めません
+func toTunnelData(t Tunnel, includeToken bool) tunnelData {
+	data := tunnelData{
+		ClientID:  t.ClientID,
+		TunnelUrl: t.TunnelUrl,
+		UpdatedAt: t.UpdatedAt.UnixMilli(),
+		CreatedAt: t.CreatedAt.UnixMilli(),
+	}
+	if includeToken {
+		data.Token = t.Token
+	}
+	return data
+}
+
+// writeJSON は200でJSONを返します
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError はWorkerと同じ形式のエラーレスポンスを返します
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"success": false, "error": message})
+}
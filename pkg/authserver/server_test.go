@@ -0,0 +1,395 @@
+package authserver
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/authclient"
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/keygen"
+)
+
+// setupServer はkeygenで鍵を生成し、.cloudflare.jsonから登録したサーバーを起動します
+func setupServer(t *testing.T, config Config) (*httptest.Server, *authclient.Client) {
+	t.Helper()
+
+	dir := t.TempDir()
+	privateKeyFile := filepath.Join(dir, "private_key.pem")
+	publicKeyFile := filepath.Join(dir, "public_key.pem")
+	if err := keygen.GenerateAndSaveSignerKeyPair(privateKeyFile, publicKeyFile, "test-client", keygen.KeyTypeECDSA, 0); err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+
+	clients, err := LoadClients(publicKeyFile + ".cloudflare.json")
+	if err != nil {
+		t.Fatalf("LoadClients() error = %v", err)
+	}
+	if config.Clients == nil {
+		config.Clients = clients
+	}
+
+	server, err := NewServer(config)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	client, err := authclient.NewClientFromFile(httpServer.URL, "test-client", privateKeyFile)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return httpServer, client
+}
+
+func TestServer_AuthenticateAndTunnel(t *testing.T) {
+	_, client := setupServer(t, Config{
+		Secrets: StaticSecrets{"test-client": {"API_KEY": "secret"}},
+	})
+
+	resp, err := client.Authenticate()
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if resp.SecretData["API_KEY"] != "secret" {
+		t.Errorf("SecretData = %v", resp.SecretData)
+	}
+	if resp.AccessToken == "" || resp.AccessTokenExpiresAt <= time.Now().Unix() {
+		t.Errorf("AccessToken = %q, expires at %d", resp.AccessToken, resp.AccessTokenExpiresAt)
+	}
+
+	if _, err := client.GetTunnel(); !errors.Is(err, authclient.ErrNotFound) {
+		t.Errorf("GetTunnel() before register error = %v, want ErrNotFound", err)
+	}
+	if _, err := client.RegisterTunnel("https://tunnel.example.com"); err != nil {
+		t.Fatalf("RegisterTunnel() error = %v", err)
+	}
+	tunnel, err := client.GetTunnel()
+	if err != nil {
+		t.Fatalf("GetTunnel() error = %v", err)
+	}
+	if tunnel.Data.TunnelUrl != "https://tunnel.example.com" || tunnel.Data.Token != resp.AccessToken {
+		t.Errorf("GetTunnel() = %+v", tunnel.Data)
+	}
+
+	// 再認証しても以前のトークンは有効期限まで有効
+	previous := resp.AccessToken
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	client.SetAccessToken(previous)
+	if _, err := client.GetTunnel(); err != nil {
+		t.Errorf("GetTunnel() with previous token error = %v", err)
+	}
+}
+
+func TestServer_TunnelUnregisterAndList(t *testing.T) {
+	_, client := setupServer(t, Config{
+		TunnelListClients:    []string{"test-client"},
+		RestrictTunnelLookup: true,
+	})
+
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if _, err := client.RegisterTunnel("https://tunnel.example.com"); err != nil {
+		t.Fatalf("RegisterTunnel() error = %v", err)
+	}
+
+	tunnels, err := client.ListTunnels()
+	if err != nil {
+		t.Fatalf("ListTunnels() error = %v", err)
+	}
+	if len(tunnels) != 1 || tunnels[0].TunnelUrl != "https://tunnel.example.com" || tunnels[0].Token != "" {
+		t.Errorf("ListTunnels() = %+v, want URL without token", tunnels)
+	}
+	if _, err := client.GetTunnelFor("other-client"); !errors.Is(err, authclient.ErrForbidden) {
+		t.Errorf("GetTunnelFor(other) error = %v, want ErrForbidden", err)
+	}
+
+	if _, err := client.UnregisterTunnel(); err != nil {
+		t.Fatalf("UnregisterTunnel() error = %v", err)
+	}
+	if _, err := client.GetTunnelFor("test-client"); !errors.Is(err, authclient.ErrTunnelNotFound) {
+		t.Errorf("GetTunnelFor() after unregister error = %v, want ErrTunnelNotFound", err)
+	}
+	if tunnels, err := client.ListTunnels(); err != nil || len(tunnels) != 0 {
+		t.Errorf("ListTunnels() after unregister = %+v, %v", tunnels, err)
+	}
+}
+
+func TestServer_TunnelLookupHidesOtherClientsToken(t *testing.T) {
+	signers := map[string]crypto.Signer{}
+	clients := StaticClients{}
+	for _, clientID := range []string{"test-client", "other-client"} {
+		signer, err := keygen.GenerateSigner(keygen.KeyTypeEd25519, 0)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		signers[clientID] = signer
+		clients[clientID] = signer.Public()
+	}
+
+	server, err := NewServer(Config{Clients: clients})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	authenticated := map[string]*authclient.Client{}
+	for clientID, signer := range signers {
+		client, err := authclient.NewClient(authclient.ClientConfig{
+			BaseURL:  httpServer.URL,
+			ClientID: clientID,
+			Signer:   signer,
+		})
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		if _, err := client.Authenticate(); err != nil {
+			t.Fatalf("Authenticate() error = %v", err)
+		}
+		authenticated[clientID] = client
+	}
+
+	owner, other := authenticated["test-client"], authenticated["other-client"]
+	if _, err := owner.RegisterTunnel("https://tunnel.example.com"); err != nil {
+		t.Fatalf("RegisterTunnel() error = %v", err)
+	}
+
+	self, err := owner.GetTunnelFor("test-client")
+	if err != nil {
+		t.Fatalf("GetTunnelFor(self) error = %v", err)
+	}
+	if self.Token != owner.GetAccessToken() {
+		t.Errorf("GetTunnelFor(self) token = %q, want own access token", self.Token)
+	}
+
+	peer, err := other.GetTunnelFor("test-client")
+	if err != nil {
+		t.Fatalf("GetTunnelFor(other) error = %v", err)
+	}
+	if peer.TunnelUrl != "https://tunnel.example.com" || peer.Token != "" {
+		t.Errorf("GetTunnelFor(other) = %+v, want URL without token", peer)
+	}
+}
+
+func TestServer_Introspect(t *testing.T) {
+	_, client := setupServer(t, Config{})
+
+	resp, err := client.Authenticate()
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	introspected, err := client.IntrospectToken(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("IntrospectToken() error = %v", err)
+	}
+	if !introspected.Active || introspected.ClientID != "test-client" || introspected.ExpiresAt != resp.AccessTokenExpiresAt {
+		t.Errorf("IntrospectToken() = %+v", introspected)
+	}
+
+	introspected, err = client.IntrospectToken("unknown-token")
+	if err != nil {
+		t.Fatalf("IntrospectToken(unknown) error = %v", err)
+	}
+	if introspected.Active || introspected.ClientID != "" {
+		t.Errorf("IntrospectToken(unknown) = %+v", introspected)
+	}
+
+	client.SetAccessToken("revoked-token")
+	if _, err := client.IntrospectToken(resp.AccessToken); !errors.Is(err, authclient.ErrUnauthorized) {
+		t.Errorf("IntrospectToken() with invalid caller token error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestServer_RejectsReplayAndUnknownClient(t *testing.T) {
+	httpServer, client := setupServer(t, Config{})
+
+	challenge, err := client.RequestChallenge()
+	if err != nil {
+		t.Fatalf("RequestChallenge() error = %v", err)
+	}
+	if _, err := client.VerifySignature(challenge.Challenge, "bad-signature"); !errors.Is(err, authclient.ErrInvalidSignature) {
+		t.Errorf("VerifySignature() error = %v, want ErrInvalidSignature", err)
+	}
+
+	// 失敗した検証でもチャレンジは消費される
+	if _, err := client.VerifySignature(challenge.Challenge, "bad-signature"); err == nil || errors.Is(err, authclient.ErrInvalidSignature) {
+		t.Errorf("VerifySignature() with used challenge error = %v, want unknown challenge", err)
+	}
+
+	signer, _ := keygen.GenerateSigner(keygen.KeyTypeEd25519, 0)
+	unknown, err := authclient.NewClient(authclient.ClientConfig{
+		BaseURL:  httpServer.URL,
+		ClientID: "unknown-client",
+		Signer:   signer,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if _, err := unknown.Authenticate(); !errors.Is(err, authclient.ErrUnauthorized) {
+		t.Errorf("Authenticate() for unknown client error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestServer_ChallengeExpiry(t *testing.T) {
+	_, client := setupServer(t, Config{ChallengeTTL: time.Nanosecond})
+
+	if _, err := client.Authenticate(); !errors.Is(err, authclient.ErrChallengeExpired) {
+		t.Errorf("Authenticate() error = %v, want ErrChallengeExpired", err)
+	}
+}
+
+func TestServer_ChallengeLimits(t *testing.T) {
+	httpServer, client := setupServer(t, Config{Challenges: NewMemoryChallengeStoreWithLimits(0, 2)})
+
+	for range 2 {
+		if _, err := client.RequestChallenge(); err != nil {
+			t.Fatalf("RequestChallenge() error = %v", err)
+		}
+	}
+	_, err := client.RequestChallenge()
+	var httpErr *authclient.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("RequestChallenge() over limit error = %v, want HTTP 429", err)
+	}
+
+	// 認証前のエンドポイントは大きなリクエストボディを読み込まない
+	body := `{"clientId":"test-client","padding":"` + strings.Repeat("x", 16<<10) + `"}`
+	for _, path := range []string{"/challenge", "/verify"} {
+		resp, err := http.Post(httpServer.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s error = %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST %s with large body status = %d, want 400", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestMemoryChallengeStore_Limits(t *testing.T) {
+	store := NewMemoryChallengeStoreWithLimits(3, 2)
+	ctx := context.Background()
+	valid := time.Now().Add(time.Minute)
+
+	put := func(challenge, clientID string, expiresAt time.Time) error {
+		return store.Put(ctx, challenge, Challenge{ClientID: clientID, ExpiresAt: expiresAt})
+	}
+
+	if err := put("a1", "a", valid); err != nil {
+		t.Fatalf("Put(a1) error = %v", err)
+	}
+	if err := put("a2", "a", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("Put(a2) error = %v", err)
+	}
+	if err := put("a3", "a", valid); !errors.Is(err, ErrTooManyChallenges) {
+		t.Errorf("Put(a3) error = %v, want ErrTooManyChallenges (per client)", err)
+	}
+	if err := put("b1", "b", valid); err != nil {
+		t.Fatalf("Put(b1) error = %v", err)
+	}
+	if err := put("c1", "c", valid); !errors.Is(err, ErrTooManyChallenges) {
+		t.Errorf("Put(c1) error = %v, want ErrTooManyChallenges (total)", err)
+	}
+
+	// 取り出したチャレンジの枠は再び使える
+	if _, ok, _ := store.Take(ctx, "a1"); !ok {
+		t.Fatal("Take(a1) = false")
+	}
+	if err := put("a3", "a", valid); err != nil {
+		t.Errorf("Put(a3) after Take error = %v", err)
+	}
+
+	// 期限切れのチャレンジは次の掃除で削除される
+	store.nextSweep = time.Time{}
+	if err := put("c1", "c", valid); err != nil {
+		t.Errorf("Put(c1) after sweep error = %v", err)
+	}
+	if _, ok, _ := store.Take(ctx, "a2"); ok {
+		t.Error("expired challenge a2 was not swept")
+	}
+}
+
+// failingChallengeStore は常に失敗するChallengeStore
+type failingChallengeStore struct{}
+
+func (failingChallengeStore) Put(ctx context.Context, challenge string, c Challenge) error {
+	return errors.New("store unavailable")
+}
+
+func (failingChallengeStore) Take(ctx context.Context, challenge string) (Challenge, bool, error) {
+	return Challenge{}, false, errors.New("store unavailable")
+}
+
+func TestServer_StoreFailure(t *testing.T) {
+	_, client := setupServer(t, Config{Challenges: failingChallengeStore{}})
+
+	_, err := client.RequestChallenge()
+	var httpErr *authclient.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("RequestChallenge() error = %v, want HTTP 500", err)
+	}
+}
+
+func TestNewServer_RequiresClients(t *testing.T) {
+	if _, err := NewServer(Config{}); err == nil {
+		t.Error("NewServer() without clients should fail")
+	}
+}
+
+func TestParseClients(t *testing.T) {
+	if _, err := ParseClients([]byte(`{"client":"not a pem"}`)); err == nil {
+		t.Error("ParseClients() with invalid PEM should fail")
+	}
+	if _, err := ParseClients([]byte(`not json`)); err == nil {
+		t.Error("ParseClients() with invalid JSON should fail")
+	}
+}
+
+func TestMemoryTokenStore_KeepsPreviousTokens(t *testing.T) {
+	store := NewMemoryTokenStore()
+	ctx := context.Background()
+
+	expired, _ := store.Issue(ctx, "test-client", time.Now().Add(-time.Second))
+	first, _ := store.Issue(ctx, "test-client", time.Now().Add(time.Hour))
+	second, _ := store.Issue(ctx, "test-client", time.Now().Add(time.Hour))
+
+	for _, token := range []string{first, second} {
+		if _, ok, _ := store.Lookup(ctx, token); !ok {
+			t.Errorf("Lookup() of a previously issued token = false, want true")
+		}
+	}
+	if _, ok := store.tokens[expired]; ok {
+		t.Error("expired token was not removed on the next issue")
+	}
+
+	// 上限を超えると最も古いトークンから無効になる
+	for range maxTokensPerClient {
+		store.Issue(ctx, "test-client", time.Now().Add(time.Hour))
+	}
+	if _, ok, _ := store.Lookup(ctx, first); ok {
+		t.Error("oldest token should be dropped over the per-client limit")
+	}
+	if n := len(store.byClient["test-client"]); n != maxTokensPerClient {
+		t.Errorf("tokens per client = %d, want %d", n, maxTokensPerClient)
+	}
+
+	other, _ := store.Issue(ctx, "other-client", time.Now().Add(time.Hour))
+	store.RevokeClient(ctx, "test-client")
+	if len(store.byClient["test-client"]) != 0 {
+		t.Error("RevokeClient() left tokens for test-client")
+	}
+	if _, ok, _ := store.Lookup(ctx, other); !ok {
+		t.Error("RevokeClient() revoked another client's token")
+	}
+}
@@ -0,0 +1,405 @@
+package authserver
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownClient はクライアントが登録されていない場合のエラー
+	ErrUnknownClient = errors.New("unknown client")
+
+	// ErrTunnelNotFound はトンネルが登録されていない場合のエラー
+	ErrTunnelNotFound = errors.New("tunnel not found")
+
+	// ErrTooManyChallenges は保留中のチャレンジ数が上限に達した場合のエラー（/challengeは429を返します）
+	ErrTooManyChallenges = errors.New("too many pending challenges")
+)
+
+// Challenge は発行済みのチャレンジ
+type Challenge struct {
+	ClientID  string
+	ExpiresAt time.Time
+}
+
+// ChallengeStore は発行済みチャレンジの保存先
+// 複数のインスタンスで動かす場合は共有ストレージに実装してください
+type ChallengeStore interface {
+	// Put はチャレンジを保存します
+	// 保留中のチャレンジが多すぎる場合はErrTooManyChallengesを返すと/challengeは429を返します
+	Put(ctx context.Context, challenge string, c Challenge) error
+
+	// Take はチャレンジを取り出して削除します（使い捨て）
+	// 存在しない場合はfalseを返します
+	Take(ctx context.Context, challenge string) (Challenge, bool, error)
+}
+
+// ClientRegistry はクライアントの公開鍵の取得先
+type ClientRegistry interface {
+	// PublicKey はクライアントの公開鍵を返します（未登録の場合はErrUnknownClient）
+	PublicKey(ctx context.Context, clientID string) (crypto.PublicKey, error)
+}
+
+// SecretStore はクライアントに返すSecret変数の取得先
+type SecretStore interface {
+	// Secrets はクライアントに返すSecret変数を返します
+	Secrets(ctx context.Context, clientID string) (map[string]string, error)
+}
+
+// RepoListStore はincludeRepoList=trueの場合に返すリポジトリURLのリストの取得先
+type RepoListStore interface {
+	// RepoList はクライアントに返すリポジトリURLのリストを返します
+	RepoList(ctx context.Context, clientID string) ([]string, error)
+}
+
+// Token は発行済みのアクセストークン
+type Token struct {
+	ClientID  string
+	ExpiresAt time.Time
+}
+
+// TokenStore はアクセストークンの発行・照会先
+type TokenStore interface {
+	// Issue はクライアントに新しいアクセストークンを発行します
+	Issue(ctx context.Context, clientID string, expiresAt time.Time) (string, error)
+
+	// Lookup は有効なアクセストークンの情報を返します（無効・期限切れの場合はfalse）
+	Lookup(ctx context.Context, token string) (Token, bool, error)
+}
+
+// Tunnel は登録されたトンネル
+type Tunnel struct {
+	ClientID  string
+	TunnelUrl string
+	Token     string
+	UpdatedAt time.Time
+	CreatedAt time.Time
+}
+
+// TunnelStore はトンネル情報の保存先
+type TunnelStore interface {
+	// Save はトンネルを登録または更新します
+	Save(ctx context.Context, clientID, tunnelUrl, token string) (Tunnel, error)
+
+	// Get はトンネルを返します（未登録の場合はErrTunnelNotFound）
+	Get(ctx context.Context, clientID string) (Tunnel, error)
+
+	// Delete はトンネルを削除し、削除したトンネルを返します（未登録の場合はErrTunnelNotFound）
+	Delete(ctx context.Context, clientID string) (Tunnel, error)
+
+	// List は登録されている全てのトンネルを返します
+	List(ctx context.Context) ([]Tunnel, error)
+}
+
+// 保留中のチャレンジ数の上限のデフォルト値
+const (
+	// DefaultMaxPendingChallenges は全体で保留できるチャレンジ数のデフォルト
+	DefaultMaxPendingChallenges = 10000
+
+	// DefaultMaxPendingChallengesPerClient はクライアントごとに保留できるチャレンジ数のデフォルト
+	DefaultMaxPendingChallengesPerClient = 16
+)
+
+// challengeSweepInterval は期限切れのチャレンジを掃除する間隔
+const challengeSweepInterval = 10 * time.Second
+
+// MemoryChallengeStore はメモリ上のChallengeStore
+// /challengeは認証前に呼び出せるため、保留中のチャレンジ数を全体とクライアントごとに制限し、
+// 上限に達した場合はErrTooManyChallengesを返します
+type MemoryChallengeStore struct {
+	maxPending   int
+	maxPerClient int
+
+	mu         sync.Mutex
+	challenges map[string]Challenge
+	perClient  map[string]int
+	nextSweep  time.Time
+}
+
+// NewMemoryChallengeStore はデフォルトの上限で新しいMemoryChallengeStoreを作成します
+func NewMemoryChallengeStore() *MemoryChallengeStore {
+	return NewMemoryChallengeStoreWithLimits(DefaultMaxPendingChallenges, DefaultMaxPendingChallengesPerClient)
+}
+
+// NewMemoryChallengeStoreWithLimits は保留中のチャレンジ数の上限を指定してMemoryChallengeStoreを作成します
+// 0以下の値はデフォルト値になります
+func NewMemoryChallengeStoreWithLimits(maxPending, maxPerClient int) *MemoryChallengeStore {
+	if maxPending <= 0 {
+		maxPending = DefaultMaxPendingChallenges
+	}
+	if maxPerClient <= 0 {
+		maxPerClient = DefaultMaxPendingChallengesPerClient
+	}
+	return &MemoryChallengeStore{
+		maxPending:   maxPending,
+		maxPerClient: maxPerClient,
+		challenges:   make(map[string]Challenge),
+		perClient:    make(map[string]int),
+	}
+}
+
+// Put はチャレンジを保存します
+// 期限切れのチャレンジの掃除は一定間隔でのみ行い、上限に達している場合はErrTooManyChallengesを返します
+func (s *MemoryChallengeStore) Put(ctx context.Context, challenge string, c Challenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if !now.Before(s.nextSweep) {
+		s.sweep(now)
+		s.nextSweep = now.Add(challengeSweepInterval)
+	}
+
+	if len(s.challenges) >= s.maxPending || s.perClient[c.ClientID] >= s.maxPerClient {
+		return ErrTooManyChallenges
+	}
+
+	if existing, ok := s.challenges[challenge]; ok {
+		s.release(existing.ClientID)
+	}
+	s.challenges[challenge] = c
+	s.perClient[c.ClientID]++
+	return nil
+}
+
+// Take はチャレンジを取り出して削除します
+// 期限切れのチャレンジは削除した上でExpiresAtを含めて返すため、呼び出し側で期限を確認してください
+func (s *MemoryChallengeStore) Take(ctx context.Context, challenge string) (Challenge, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.challenges[challenge]
+	if ok {
+		delete(s.challenges, challenge)
+		s.release(c.ClientID)
+	}
+	return c, ok, nil
+}
+
+// sweep は期限切れのチャレンジを削除します（s.muを保持して呼び出す）
+func (s *MemoryChallengeStore) sweep(now time.Time) {
+	for key, existing := range s.challenges {
+		if now.After(existing.ExpiresAt) {
+			delete(s.challenges, key)
+			s.release(existing.ClientID)
+		}
+	}
+}
+
+// release はクライアントの保留中のチャレンジ数を減らします（s.muを保持して呼び出す）
+func (s *MemoryChallengeStore) release(clientID string) {
+	if s.perClient[clientID] <= 1 {
+		delete(s.perClient, clientID)
+		return
+	}
+	s.perClient[clientID]--
+}
+
+// StaticClients はclientIDから公開鍵への固定のClientRegistry
+type StaticClients map[string]crypto.PublicKey
+
+// PublicKey はクライアントの公開鍵を返します
+func (c StaticClients) PublicKey(ctx context.Context, clientID string) (crypto.PublicKey, error) {
+	publicKey, ok := c[clientID]
+	if !ok {
+		return nil, ErrUnknownClient
+	}
+	return publicKey, nil
+}
+
+// StaticSecrets はclientIDからSecret変数への固定のSecretStore
+type StaticSecrets map[string]map[string]string
+
+// Secrets はクライアントのSecret変数の複製を返します（未登録の場合は空）
+func (s StaticSecrets) Secrets(ctx context.Context, clientID string) (map[string]string, error) {
+	secrets := maps.Clone(s[clientID])
+	if secrets == nil {
+		secrets = map[string]string{}
+	}
+	return secrets, nil
+}
+
+// StaticRepoLists はclientIDからリポジトリURLのリストへの固定のRepoListStore
+type StaticRepoLists map[string][]string
+
+// RepoList はクライアントのリポジトリURLのリストの複製を返します（未登録の場合はnil）
+func (s StaticRepoLists) RepoList(ctx context.Context, clientID string) ([]string, error) {
+	return slices.Clone(s[clientID]), nil
+}
+
+// maxTokensPerClient はMemoryTokenStoreがクライアントごとに保持する有効なトークンの上限
+// 超えた場合は最も古いトークンから無効にします
+const maxTokensPerClient = 32
+
+// MemoryTokenStore はメモリ上のTokenStore
+// 新しいトークンを発行しても同じクライアントの以前のトークンは有効期限まで有効です
+// （同じクライアントIDを共有する複数のインスタンスや、更新中のトークンの猶予期間のため）
+type MemoryTokenStore struct {
+	mu       sync.Mutex
+	tokens   map[string]Token
+	byClient map[string][]string
+}
+
+// NewMemoryTokenStore は新しいMemoryTokenStoreを作成します
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		tokens:   make(map[string]Token),
+		byClient: make(map[string][]string),
+	}
+}
+
+// Issue は新しいアクセストークンを発行します
+// 同じクライアントの期限切れのトークンはこのときに削除します
+func (s *MemoryTokenStore) Issue(ctx context.Context, clientID string, expiresAt time.Time) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	live := s.byClient[clientID][:0]
+	for _, previous := range s.byClient[clientID] {
+		if t, ok := s.tokens[previous]; ok && !now.After(t.ExpiresAt) {
+			live = append(live, previous)
+		} else {
+			delete(s.tokens, previous)
+		}
+	}
+	for len(live) >= maxTokensPerClient {
+		delete(s.tokens, live[0])
+		live = live[1:]
+	}
+
+	s.tokens[token] = Token{ClientID: clientID, ExpiresAt: expiresAt}
+	s.byClient[clientID] = append(live, token)
+	return token, nil
+}
+
+// Lookup は有効なアクセストークンの情報を返します
+func (s *MemoryTokenStore) Lookup(ctx context.Context, token string) (Token, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[token]
+	if !ok || time.Now().After(t.ExpiresAt) {
+		return Token{}, false, nil
+	}
+	return t, true, nil
+}
+
+// Revoke はアクセストークンを無効にします（存在しない場合は何もしません）
+func (s *MemoryTokenStore) Revoke(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[token]
+	if !ok {
+		return nil
+	}
+	delete(s.tokens, token)
+	s.byClient[t.ClientID] = slices.DeleteFunc(s.byClient[t.ClientID], func(issued string) bool {
+		return issued == token
+	})
+	if len(s.byClient[t.ClientID]) == 0 {
+		delete(s.byClient, t.ClientID)
+	}
+	return nil
+}
+
+// RevokeClient はクライアントに発行した全てのアクセストークンを無効にします
+func (s *MemoryTokenStore) RevokeClient(ctx context.Context, clientID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.byClient[clientID] {
+		delete(s.tokens, token)
+	}
+	delete(s.byClient, clientID)
+	return nil
+}
+
+// MemoryTunnelStore はメモリ上のTunnelStore
+type MemoryTunnelStore struct {
+	mu      sync.Mutex
+	tunnels map[string]Tunnel
+}
+
+// NewMemoryTunnelStore は新しいMemoryTunnelStoreを作成します
+func NewMemoryTunnelStore() *MemoryTunnelStore {
+	return &MemoryTunnelStore{tunnels: make(map[string]Tunnel)}
+}
+
+// Save はトンネルを登録または更新します
+func (s *MemoryTunnelStore) Save(ctx context.Context, clientID, tunnelUrl, token string) (Tunnel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	tunnel, ok := s.tunnels[clientID]
+	if !ok {
+		tunnel.CreatedAt = now
+	}
+	tunnel.ClientID = clientID
+	tunnel.TunnelUrl = tunnelUrl
+	tunnel.Token = token
+	tunnel.UpdatedAt = now
+	s.tunnels[clientID] = tunnel
+	return tunnel, nil
+}
+
+// Get はトンネルを返します
+func (s *MemoryTunnelStore) Get(ctx context.Context, clientID string) (Tunnel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tunnel, ok := s.tunnels[clientID]
+	if !ok {
+		return Tunnel{}, ErrTunnelNotFound
+	}
+	return tunnel, nil
+}
+
+// Delete はトンネルを削除します
+func (s *MemoryTunnelStore) Delete(ctx context.Context, clientID string) (Tunnel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tunnel, ok := s.tunnels[clientID]
+	if !ok {
+		return Tunnel{}, ErrTunnelNotFound
+	}
+	delete(s.tunnels, clientID)
+	return tunnel, nil
+}
+
+// List は全てのトンネルをクライアントID順に返します
+func (s *MemoryTunnelStore) List(ctx context.Context) ([]Tunnel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tunnels := slices.Collect(maps.Values(s.tunnels))
+	slices.SortFunc(tunnels, func(a, b Tunnel) int {
+		return strings.Compare(a.ClientID, b.ClientID)
+	})
+	return tunnels, nil
+}
+
+// randomToken はBase64エンコードされた32バイトの乱数を返します
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,457 @@
+// Package authtest はテスト用のCloudflare Auth Workerをプロセス内で提供します
+//
+// プロトコルの処理はauthserver.Serverに任せ、障害の注入と状態の確認に必要な機能を加えます
+// 実際のWorkerと同じく公開鍵で署名を検証し、チャレンジの有効期限と使い捨てを強制します
+// 429・5xx・遅延・期限切れチャレンジなどの障害を注入してクライアントの挙動を確認できます
+//
+//	server := authtest.NewServer(authtest.Config{})
+//	defer server.Close()
+//	server.AddClient("my-client", signer.Public(), map[string]string{"API_KEY": "test"})
+//
+//	client, _ := authclient.NewClient(authclient.ClientConfig{
+//	    BaseURL:  server.URL,
+//	    ClientID: "my-client",
+//	    Signer:   signer,
+//	})
+package authtest
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/authserver"
+)
+
+// Config はテスト用Workerの設定
+type Config struct {
+	// ChallengeTTL はチャレンジの有効期間（デフォルト: 60秒）
+	ChallengeTTL time.Duration
+
+	// TokenTTL はアクセストークンの有効期間（デフォルト: 1時間）
+	TokenTTL time.Duration
+
+	// TunnelListClients はGET /tunnelsで一覧の取得を許可するクライアント（それ以外は403）
+	TunnelListClients []string
+
+	// RestrictTunnelLookup がtrueの場合、GET /tunnel/{clientId}で他のクライアントの参照を403にします
+	RestrictTunnelLookup bool
+}
+
+// Fault は注入する障害
+type Fault struct {
+	// Status は返すHTTPステータス（0の場合は通常の処理を行い、Delayのみ適用）
+	Status int
+
+	// RetryAfter はRetry-Afterヘッダーに設定する待機時間（0の場合は付与しない）
+	RetryAfter time.Duration
+
+	// Delay は応答前に待機する時間
+	Delay time.Duration
+
+	// Times は障害を適用する回数（0の場合はClearFaultsまで常に適用）
+	Times int
+}
+
+// TunnelData はサーバーに保存されているトンネル情報
+type TunnelData struct {
+	ClientID  string `json:"clientId"`
+	TunnelUrl string `json:"tunnelUrl"`
+	Token     string `json:"token"`
+	UpdatedAt int64  `json:"updatedAt"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// VerifyRequest はテスト用Workerが受け付けた/verifyのリクエスト
+type VerifyRequest struct {
+	ClientID        string `json:"clientId"`
+	Challenge       string `json:"challenge"`
+	Signature       string `json:"signature"`
+	Algorithm       string `json:"algorithm,omitempty"`
+	RepoUrl         string `json:"repoUrl,omitempty"`
+	GrpcEndpoint    string `json:"grpcEndpoint,omitempty"`
+	IncludeRepoList bool   `json:"includeRepoList,omitempty"`
+	TunnelUrl       string `json:"tunnelUrl,omitempty"`
+}
+
+// Server はテスト用のCloudflare Auth Worker
+type Server struct {
+	*httptest.Server
+
+	worker     *authserver.Server
+	clients    *clientRegistry
+	challenges *challengeStore
+	tokens     *tokenStore
+	tunnels    *authserver.MemoryTunnelStore
+
+	mu         sync.Mutex
+	faults     map[string][]*Fault
+	requests   map[string]int
+	lastVerify map[string]VerifyRequest
+}
+
+// NewServer はテスト用Workerを起動します
+// 使用後はCloseを呼び出してください
+func NewServer(config Config) *Server {
+	s := newServer(config)
+	s.Server = httptest.NewServer(s.Handler())
+	return s
+}
+
+// NewTLSServer はHTTPSでテスト用Workerを起動します
+// クライアントにはs.Client()のHTTPクライアントを使用してください
+func NewTLSServer(config Config) *Server {
+	s := newServer(config)
+	s.Server = httptest.NewTLSServer(s.Handler())
+	return s
+}
+
+// newServer は起動前のテスト用Workerを作成します
+func newServer(config Config) *Server {
+	s := &Server{
+		clients:    &clientRegistry{clients: make(map[string]*registeredClient)},
+		challenges: &challengeStore{MemoryChallengeStore: authserver.NewMemoryChallengeStore()},
+		tokens:     &tokenStore{MemoryTokenStore: authserver.NewMemoryTokenStore(), issued: make(map[string]string)},
+		tunnels:    authserver.NewMemoryTunnelStore(),
+		faults:     make(map[string][]*Fault),
+		requests:   make(map[string]int),
+		lastVerify: make(map[string]VerifyRequest),
+	}
+
+	worker, err := authserver.NewServer(authserver.Config{
+		Clients:              s.clients,
+		Secrets:              s.clients,
+		RepoLists:            s.clients,
+		Challenges:           s.challenges,
+		Tokens:               s.tokens,
+		Tunnels:              s.tunnels,
+		ChallengeTTL:         config.ChallengeTTL,
+		TokenTTL:             config.TokenTTL,
+		TunnelListClients:    config.TunnelListClients,
+		RestrictTunnelLookup: config.RestrictTunnelLookup,
+	})
+	if err != nil {
+		// Clientsは常に指定しているため到達しない
+		panic(err)
+	}
+	s.worker = worker
+	return s
+}
+
+// Handler はWorkerのエンドポイントに障害の注入とリクエストの記録を加えたハンドラを返します
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fault := s.takeFault(r.URL.Path)
+		if fault != nil {
+			if fault.Delay > 0 {
+				select {
+				case <-time.After(fault.Delay):
+				case <-r.Context().Done():
+					return
+				}
+			}
+			if fault.Status != 0 {
+				if fault.RetryAfter > 0 {
+					w.Header().Set("Retry-After", strconv.Itoa(int(fault.RetryAfter.Round(time.Second)/time.Second)))
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(fault.Status)
+				json.NewEncoder(w).Encode(map[string]any{"success": false, "error": http.StatusText(fault.Status)})
+				return
+			}
+		}
+
+		if r.Method != http.MethodPost || r.URL.Path != "/verify" {
+			s.worker.ServeHTTP(w, r)
+			return
+		}
+
+		// 成功した検証のリクエストを記録するため、ボディを読み取ってから渡す
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		s.worker.ServeHTTP(rec, r)
+
+		var req VerifyRequest
+		if rec.status == http.StatusOK && json.Unmarshal(body, &req) == nil {
+			s.mu.Lock()
+			s.lastVerify[req.ClientID] = req
+			s.mu.Unlock()
+		}
+	})
+}
+
+// AddClient はクライアントを登録します
+// 既に登録されている場合は公開鍵とSecret変数を置き換えます
+func (s *Server) AddClient(clientID string, publicKey crypto.PublicKey, secrets map[string]string) {
+	s.clients.mu.Lock()
+	defer s.clients.mu.Unlock()
+
+	client, ok := s.clients.clients[clientID]
+	if !ok {
+		client = &registeredClient{}
+		s.clients.clients[clientID] = client
+	}
+	client.publicKey = publicKey
+	client.secrets = maps.Clone(secrets)
+}
+
+// LoadAuthorizedClients はWorkerのAUTHORIZED_CLIENTSと同じ形式
+// （{"clientId": "PEM形式の公開鍵"}）のJSONからクライアントを登録します
+func (s *Server) LoadAuthorizedClients(data []byte) error {
+	clients, err := authserver.ParseClients(data)
+	if err != nil {
+		return fmt.Errorf("failed to load authorized clients: %w", err)
+	}
+
+	for clientID, publicKey := range clients {
+		s.AddClient(clientID, publicKey, nil)
+	}
+	return nil
+}
+
+// SetSecrets はクライアントに返すSecret変数を設定します
+func (s *Server) SetSecrets(clientID string, secrets map[string]string) {
+	s.clients.mu.Lock()
+	defer s.clients.mu.Unlock()
+
+	if client, ok := s.clients.clients[clientID]; ok {
+		client.secrets = maps.Clone(secrets)
+	}
+}
+
+// SetRepoList はincludeRepoList=trueの場合に返すリポジトリURLのリストを設定します
+func (s *Server) SetRepoList(clientID string, repoList []string) {
+	s.clients.mu.Lock()
+	defer s.clients.mu.Unlock()
+
+	if client, ok := s.clients.clients[clientID]; ok {
+		client.repoList = slices.Clone(repoList)
+	}
+}
+
+// InjectFault はpathへのリクエストに障害を注入します
+// pathが空の場合は全てのパスに適用されます。複数の障害は登録順に適用されます
+func (s *Server) InjectFault(path string, fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[path] = append(s.faults[path], &fault)
+}
+
+// ClearFaults は注入した全ての障害を取り除きます
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	s.faults = make(map[string][]*Fault)
+	s.mu.Unlock()
+
+	s.ExpireNextChallenges(0)
+}
+
+// ExpireNextChallenges は次のn回のチャレンジを発行時点で期限切れにします
+func (s *Server) ExpireNextChallenges(n int) {
+	s.challenges.mu.Lock()
+	defer s.challenges.mu.Unlock()
+	s.challenges.expireNext = n
+}
+
+// RevokeAccessToken はクライアントに発行した全てのアクセストークンを無効にします
+// 再認証しても以前のトークンは有効期限まで有効なため、失効させる場合はこれを使用します
+func (s *Server) RevokeAccessToken(clientID string) {
+	s.tokens.revoke(clientID)
+}
+
+// AccessToken はクライアントに最後に発行した有効なアクセストークンを返します
+func (s *Server) AccessToken(clientID string) string {
+	return s.tokens.current(clientID)
+}
+
+// Tunnel は登録されたトンネル情報を返します
+func (s *Server) Tunnel(clientID string) (TunnelData, bool) {
+	tunnel, err := s.tunnels.Get(context.Background(), clientID)
+	if err != nil {
+		return TunnelData{}, false
+	}
+	return TunnelData{
+		ClientID:  tunnel.ClientID,
+		TunnelUrl: tunnel.TunnelUrl,
+		Token:     tunnel.Token,
+		UpdatedAt: tunnel.UpdatedAt.UnixMilli(),
+		CreatedAt: tunnel.CreatedAt.UnixMilli(),
+	}, true
+}
+
+// Requests はpathへのリクエスト数を返します（障害を注入したリクエストも含みます）
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// LastVerifyRequest はクライアントから最後に受け付けた/verifyリクエストを返します
+func (s *Server) LastVerifyRequest(clientID string) (VerifyRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, ok := s.lastVerify[clientID]
+	return req, ok
+}
+
+// takeFault はリクエスト数を記録し、適用する障害を返します
+func (s *Server) takeFault(path string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[path]++
+
+	for _, key := range []string{path, ""} {
+		faults := s.faults[key]
+		if len(faults) == 0 {
+			continue
+		}
+		fault := faults[0]
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				s.faults[key] = faults[1:]
+			}
+		}
+		applied := *fault
+		return &applied
+	}
+	return nil
+}
+
+// statusRecorder は応答したHTTPステータスを記録します
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// registeredClient は登録されたクライアント
+type registeredClient struct {
+	publicKey crypto.PublicKey
+	secrets   map[string]string
+	repoList  []string
+}
+
+// clientRegistry はAddClientで登録したクライアントを提供する
+// authserver.ClientRegistry・SecretStore・RepoListStore
+type clientRegistry struct {
+	mu      sync.Mutex
+	clients map[string]*registeredClient
+}
+
+func (r *clientRegistry) PublicKey(ctx context.Context, clientID string) (crypto.PublicKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, ok := r.clients[clientID]
+	if !ok {
+		return nil, authserver.ErrUnknownClient
+	}
+	return client.publicKey, nil
+}
+
+func (r *clientRegistry) Secrets(ctx context.Context, clientID string) (map[string]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	secrets := map[string]string{}
+	if client, ok := r.clients[clientID]; ok && client.secrets != nil {
+		secrets = maps.Clone(client.secrets)
+	}
+	return secrets, nil
+}
+
+func (r *clientRegistry) RepoList(ctx context.Context, clientID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if client, ok := r.clients[clientID]; ok {
+		return slices.Clone(client.repoList), nil
+	}
+	return nil, nil
+}
+
+// challengeStore はExpireNextChallengesで指定した回数だけ期限切れのチャレンジを保存します
+type challengeStore struct {
+	*authserver.MemoryChallengeStore
+
+	mu         sync.Mutex
+	expireNext int
+}
+
+func (s *challengeStore) Put(ctx context.Context, challenge string, c authserver.Challenge) error {
+	s.mu.Lock()
+	if s.expireNext > 0 {
+		s.expireNext--
+		c.ExpiresAt = time.Now().Add(-time.Second)
+	}
+	s.mu.Unlock()
+
+	return s.MemoryChallengeStore.Put(ctx, challenge, c)
+}
+
+// tokenStore はクライアントごとに最後に発行したアクセストークンを記録します
+type tokenStore struct {
+	*authserver.MemoryTokenStore
+
+	mu     sync.Mutex
+	issued map[string]string
+}
+
+func (s *tokenStore) Issue(ctx context.Context, clientID string, expiresAt time.Time) (string, error) {
+	token, err := s.MemoryTokenStore.Issue(ctx, clientID, expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.issued[clientID] = token
+	return token, nil
+}
+
+// current はクライアントに最後に発行したトークンが有効であれば返します
+func (s *tokenStore) current(clientID string) string {
+	s.mu.Lock()
+	token := s.issued[clientID]
+	s.mu.Unlock()
+
+	if token == "" {
+		return ""
+	}
+	if _, ok, _ := s.Lookup(context.Background(), token); !ok {
+		return ""
+	}
+	return token
+}
+
+// revoke はクライアントに発行した全てのトークンを無効にします
+func (s *tokenStore) revoke(clientID string) {
+	s.mu.Lock()
+	delete(s.issued, clientID)
+	s.mu.Unlock()
+
+	s.RevokeClient(context.Background(), clientID)
+}
@@ -0,0 +1,359 @@
+package authtest
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	internalcrypto "github.com/yhonda-ohishi-pub-dev/go_auth/internal/crypto"
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/authclient"
+	"github.com/yhonda-ohishi-pub-dev/go_auth/pkg/keygen"
+)
+
+func newSigner(t *testing.T, keyType string) crypto.Signer {
+	t.Helper()
+
+	signer, err := keygen.GenerateSigner(keyType, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return signer
+}
+
+func newClient(t *testing.T, server *Server, signer crypto.Signer) *authclient.Client {
+	t.Helper()
+
+	client, err := authclient.NewClient(authclient.ClientConfig{
+		BaseURL:  server.URL,
+		ClientID: "test-client",
+		Signer:   signer,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
+
+func TestServer_Authenticate(t *testing.T) {
+	for _, keyType := range []string{keygen.KeyTypeRSA, keygen.KeyTypeECDSA, keygen.KeyTypeEd25519} {
+		t.Run(keyType, func(t *testing.T) {
+			server := NewServer(Config{})
+			defer server.Close()
+
+			signer := newSigner(t, keyType)
+			server.AddClient("test-client", signer.Public(), map[string]string{"API_KEY": "secret"})
+
+			resp, err := newClient(t, server, signer).Authenticate()
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if resp.SecretData["API_KEY"] != "secret" {
+				t.Errorf("SecretData = %v", resp.SecretData)
+			}
+			if resp.AccessToken == "" || resp.AccessToken != server.AccessToken("test-client") {
+				t.Errorf("AccessToken = %q, server issued %q", resp.AccessToken, server.AccessToken("test-client"))
+			}
+		})
+	}
+}
+
+func TestServer_RepoList(t *testing.T) {
+	server := NewServer(Config{})
+	defer server.Close()
+
+	signer := newSigner(t, keygen.KeyTypeEd25519)
+	server.AddClient("test-client", signer.Public(), nil)
+	server.SetRepoList("test-client", []string{"https://github.com/example/repo"})
+
+	client, err := authclient.NewClient(authclient.ClientConfig{
+		BaseURL:         server.URL,
+		ClientID:        "test-client",
+		Signer:          signer,
+		IncludeRepoList: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	resp, err := client.Authenticate()
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if len(resp.RepoList) != 1 || resp.RepoList[0] != "https://github.com/example/repo" {
+		t.Errorf("RepoList = %v", resp.RepoList)
+	}
+	if req, ok := server.LastVerifyRequest("test-client"); !ok || !req.IncludeRepoList {
+		t.Errorf("LastVerifyRequest() = %+v, %v", req, ok)
+	}
+
+	// includeRepoListを指定しない場合は返さない
+	if resp, err := newClient(t, server, signer).Authenticate(); err != nil || resp.RepoList != nil {
+		t.Errorf("Authenticate() without includeRepoList = %v, %v", resp, err)
+	}
+}
+
+func TestServer_RejectsWrongKey(t *testing.T) {
+	server := NewServer(Config{})
+	defer server.Close()
+
+	server.AddClient("test-client", newSigner(t, keygen.KeyTypeRSA).Public(), nil)
+
+	_, err := newClient(t, server, newSigner(t, keygen.KeyTypeRSA)).Authenticate()
+	if !errors.Is(err, authclient.ErrInvalidSignature) {
+		t.Errorf("Authenticate() error = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestServer_ChallengeSingleUse(t *testing.T) {
+	server := NewServer(Config{})
+	defer server.Close()
+
+	signer := newSigner(t, keygen.KeyTypeRSA)
+	server.AddClient("test-client", signer.Public(), nil)
+	client := newClient(t, server, signer)
+
+	challenge, err := client.RequestChallenge()
+	if err != nil {
+		t.Fatalf("RequestChallenge() error = %v", err)
+	}
+	signature, err := internalcrypto.SignChallengeWithAlgorithm(signer, internalcrypto.AlgorithmRS256, challenge.Challenge)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	if _, err := client.VerifySignature(challenge.Challenge, signature); err != nil {
+		t.Fatalf("first VerifySignature() error = %v", err)
+	}
+	if _, err := client.VerifySignature(challenge.Challenge, signature); !errors.Is(err, authclient.ErrUnauthorized) {
+		t.Errorf("second VerifySignature() error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestServer_ExpiredChallenge(t *testing.T) {
+	server := NewServer(Config{})
+	defer server.Close()
+
+	signer := newSigner(t, keygen.KeyTypeRSA)
+	server.AddClient("test-client", signer.Public(), nil)
+	server.ExpireNextChallenges(1)
+
+	// 時計のずれの許容範囲内のため、クライアントは検証を送信し、
+	// Workerの期限切れ応答を受けてチャレンジを取り直す
+	if _, err := newClient(t, server, signer).Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if got := server.Requests("/challenge"); got != 2 {
+		t.Errorf("Requests(/challenge) = %d, want 2", got)
+	}
+	if got := server.Requests("/verify"); got != 2 {
+		t.Errorf("Requests(/verify) = %d, want 2", got)
+	}
+}
+
+func TestServer_InjectFault(t *testing.T) {
+	server := NewServer(Config{})
+	defer server.Close()
+
+	signer := newSigner(t, keygen.KeyTypeRSA)
+	server.AddClient("test-client", signer.Public(), nil)
+	client := newClient(t, server, signer)
+
+	server.InjectFault("/challenge", Fault{Status: http.StatusTooManyRequests, RetryAfter: 3 * time.Second, Times: 1})
+
+	_, err := client.Authenticate()
+	var httpErr *authclient.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests || httpErr.RetryAfter != 3*time.Second {
+		t.Fatalf("Authenticate() error = %v, want 429 with Retry-After 3s", err)
+	}
+
+	// 1回分の障害は消費済み
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	server.InjectFault("", Fault{Status: http.StatusBadGateway, Times: 2})
+	client.SetRetry(2, time.Millisecond)
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() with retry error = %v", err)
+	}
+
+	server.InjectFault("/verify", Fault{Delay: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.AuthenticateContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("AuthenticateContext() error = %v, want DeadlineExceeded", err)
+	}
+
+	server.ClearFaults()
+	if _, err := client.Authenticate(); err != nil {
+		t.Errorf("Authenticate() after ClearFaults error = %v", err)
+	}
+}
+
+func TestServer_LoadAuthorizedClients(t *testing.T) {
+	server := NewServer(Config{})
+	defer server.Close()
+
+	signer := newSigner(t, keygen.KeyTypeECDSA)
+	publicKeyPEM, err := keygen.MarshalPublicKeyPEM(signer.Public())
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	data, _ := json.Marshal(map[string]string{"test-client": string(publicKeyPEM)})
+
+	if err := server.LoadAuthorizedClients(data); err != nil {
+		t.Fatalf("LoadAuthorizedClients() error = %v", err)
+	}
+	server.SetSecrets("test-client", map[string]string{"DB_URL": "postgres://db"})
+
+	resp, err := newClient(t, server, signer).Authenticate()
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if resp.SecretData["DB_URL"] != "postgres://db" {
+		t.Errorf("SecretData = %v", resp.SecretData)
+	}
+
+	if err := server.LoadAuthorizedClients([]byte(`{"bad":"not a pem"}`)); err == nil {
+		t.Error("LoadAuthorizedClients() with invalid PEM should fail")
+	}
+}
+
+func TestServer_Tunnel(t *testing.T) {
+	server := NewServer(Config{})
+	defer server.Close()
+
+	signer := newSigner(t, keygen.KeyTypeRSA)
+	server.AddClient("test-client", signer.Public(), nil)
+	client := newClient(t, server, signer)
+
+	if _, err := client.GetTunnel(); err == nil {
+		t.Fatal("GetTunnel() before authentication should fail")
+	}
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if _, err := client.GetTunnel(); !errors.Is(err, authclient.ErrNotFound) {
+		t.Errorf("GetTunnel() before register error = %v, want ErrNotFound", err)
+	}
+
+	if _, err := client.RegisterTunnel("https://tunnel.example.com"); err != nil {
+		t.Fatalf("RegisterTunnel() error = %v", err)
+	}
+	tunnel, err := client.GetTunnel()
+	if err != nil {
+		t.Fatalf("GetTunnel() error = %v", err)
+	}
+	if tunnel.Data.TunnelUrl != "https://tunnel.example.com" || tunnel.Data.Token != server.AccessToken("test-client") {
+		t.Errorf("GetTunnel() = %+v", tunnel.Data)
+	}
+
+	server.RevokeAccessToken("test-client")
+	if _, err := client.GetTunnel(); !errors.Is(err, authclient.ErrUnauthorized) {
+		t.Errorf("GetTunnel() with revoked token error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestServer_TunnelUnregisterAndList(t *testing.T) {
+	server := NewServer(Config{TunnelListClients: []string{"test-client"}})
+	defer server.Close()
+
+	signer := newSigner(t, keygen.KeyTypeRSA)
+	server.AddClient("test-client", signer.Public(), nil)
+	client := newClient(t, server, signer)
+
+	otherSigner := newSigner(t, keygen.KeyTypeECDSA)
+	server.AddClient("other-client", otherSigner.Public(), nil)
+	other, err := authclient.NewClient(authclient.ClientConfig{
+		BaseURL:  server.URL,
+		ClientID: "other-client",
+		Signer:   otherSigner,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if _, err := other.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if _, err := client.RegisterTunnel("https://tunnel.example.com"); err != nil {
+		t.Fatalf("RegisterTunnel() error = %v", err)
+	}
+	if _, err := other.RegisterTunnel("https://other.example.com"); err != nil {
+		t.Fatalf("RegisterTunnel() error = %v", err)
+	}
+
+	tunnels, err := client.ListTunnels()
+	if err != nil {
+		t.Fatalf("ListTunnels() error = %v", err)
+	}
+	if len(tunnels) != 2 || tunnels[0].ClientID != "other-client" || tunnels[1].ClientID != "test-client" {
+		t.Errorf("ListTunnels() = %+v", tunnels)
+	}
+	for _, tunnel := range tunnels {
+		if tunnel.Token != "" {
+			t.Errorf("ListTunnels() exposed the token of %s", tunnel.ClientID)
+		}
+	}
+	if _, err := other.ListTunnels(); !errors.Is(err, authclient.ErrForbidden) {
+		t.Errorf("ListTunnels() by unlisted client error = %v, want ErrForbidden", err)
+	}
+
+	peer, err := client.GetTunnelFor("other-client")
+	if err != nil {
+		t.Fatalf("GetTunnelFor() error = %v", err)
+	}
+	if peer.TunnelUrl != "https://other.example.com" || peer.Token != "" {
+		t.Errorf("GetTunnelFor() = %+v, want URL without token", peer)
+	}
+
+	removed, err := client.UnregisterTunnel()
+	if err != nil {
+		t.Fatalf("UnregisterTunnel() error = %v", err)
+	}
+	if removed.TunnelUrl != "https://tunnel.example.com" {
+		t.Errorf("UnregisterTunnel() = %+v", removed)
+	}
+	if _, ok := server.Tunnel("test-client"); ok {
+		t.Error("tunnel should be removed from the server")
+	}
+	if _, err := client.UnregisterTunnel(); !errors.Is(err, authclient.ErrTunnelNotFound) {
+		t.Errorf("second UnregisterTunnel() error = %v, want ErrTunnelNotFound", err)
+	}
+	if _, err := other.GetTunnelFor("test-client"); !errors.Is(err, authclient.ErrTunnelNotFound) {
+		t.Errorf("GetTunnelFor() after unregister error = %v, want ErrTunnelNotFound", err)
+	}
+}
+
+func TestServer_RestrictTunnelLookup(t *testing.T) {
+	server := NewServer(Config{RestrictTunnelLookup: true})
+	defer server.Close()
+
+	signer := newSigner(t, keygen.KeyTypeRSA)
+	server.AddClient("test-client", signer.Public(), nil)
+	client := newClient(t, server, signer)
+
+	if _, err := client.Authenticate(); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if _, err := client.RegisterTunnel("https://tunnel.example.com"); err != nil {
+		t.Fatalf("RegisterTunnel() error = %v", err)
+	}
+
+	if _, err := client.GetTunnelFor("test-client"); err != nil {
+		t.Errorf("GetTunnelFor(self) error = %v", err)
+	}
+	if _, err := client.GetTunnelFor("other-client"); !errors.Is(err, authclient.ErrForbidden) {
+		t.Errorf("GetTunnelFor(other) error = %v, want ErrForbidden", err)
+	}
+	if _, err := client.ListTunnels(); !errors.Is(err, authclient.ErrForbidden) {
+		t.Errorf("ListTunnels() error = %v, want ErrForbidden", err)
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"strings"
 )
 
 var (
@@ -137,26 +136,11 @@ func GenerateAndSaveKeyPair(privateKeyFile, publicKeyFile, clientID string, bits
 
 // SaveCloudflareConfig はCloudflare Worker用のワンライナーJSON設定を保存します
 func SaveCloudflareConfig(filename, clientID string, publicKey *rsa.PublicKey) error {
-	// 公開鍵をPEM形式にエンコード
-	publicKeyPEM, err := EncodePublicKeyToPEM(publicKey)
-	if err != nil {
-		return err
-	}
-
-	// 改行を\nに置換してワンライナー化
-	pemStr := string(publicKeyPEM)
-	pemStr = strings.ReplaceAll(pemStr, "\n", "\\n")
-	pemStr = strings.TrimSuffix(pemStr, "\\n") // 末尾の余分な\nを削除
-
-	// JSON形式で保存
-	jsonStr := fmt.Sprintf(`{"%s":"%s"}`, clientID, pemStr)
-
-	// ファイルに保存
-	if err := os.WriteFile(filename, []byte(jsonStr), 0644); err != nil {
-		return fmt.Errorf("failed to write cloudflare config file: %w", err)
+	if publicKey == nil {
+		return errors.New("public key is nil")
 	}
 
-	return nil
+	return SaveCloudflareConfigForKey(filename, clientID, publicKey)
 }
 
 // LoadPrivateKey はPEMファイルから秘密鍵を読み込みます
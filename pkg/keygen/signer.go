@@ -0,0 +1,195 @@
+package keygen
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 鍵の種類
+const (
+	// KeyTypeRSA はRSA鍵（RS256/PS256）
+	KeyTypeRSA = "rsa"
+
+	// KeyTypeECDSA はECDSA P-256鍵（ES256）
+	KeyTypeECDSA = "ecdsa"
+
+	// KeyTypeEd25519 はEd25519鍵（EdDSA）
+	KeyTypeEd25519 = "ed25519"
+)
+
+// ErrUnsupportedKeyType は鍵の種類がサポートされていない場合のエラー
+var ErrUnsupportedKeyType = errors.New("unsupported key type: must be rsa, ecdsa or ed25519")
+
+// GenerateSigner は指定された種類の秘密鍵を生成します
+// bitsはRSA鍵の場合のみ使用されます
+func GenerateSigner(keyType string, bits int) (crypto.Signer, error) {
+	switch keyType {
+	case KeyTypeRSA, "":
+		return GeneratePrivateKey(bits)
+	case KeyTypeECDSA:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate private key: %w", err)
+		}
+		return key, nil
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate private key: %w", err)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%w: got %q", ErrUnsupportedKeyType, keyType)
+	}
+}
+
+// MarshalPrivateKeyPEM は任意の種類の秘密鍵をPKCS#8のPEM形式にエンコードします
+func MarshalPrivateKeyPEM(privateKey crypto.PrivateKey) ([]byte, error) {
+	if privateKey == nil {
+		return nil, errors.New("private key is nil")
+	}
+
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: privateKeyBytes,
+	}), nil
+}
+
+// MarshalPublicKeyPEM は任意の種類の公開鍵をPKIXのPEM形式にエンコードします
+func MarshalPublicKeyPEM(publicKey crypto.PublicKey) ([]byte, error) {
+	if publicKey == nil {
+		return nil, errors.New("public key is nil")
+	}
+
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: publicKeyBytes,
+	}), nil
+}
+
+// ParseSignerPEM はPEMデータから任意の種類の秘密鍵をcrypto.Signerとしてパースします
+// PKCS#8、PKCS#1（RSA）、SEC 1（ECDSA）形式に対応しています
+func ParseSignerPEM(pemData []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, ErrInvalidPEMBlock
+	}
+
+	// PKCS#8形式をパース
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("%w: %T does not implement crypto.Signer", ErrInvalidKeyType, key)
+		}
+		return signer, nil
+	}
+
+	// PKCS#1形式（RSA）を試す
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	// SEC 1形式（ECDSA）を試す
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	return key, nil
+}
+
+// LoadSigner はPEMファイルから任意の種類の秘密鍵をcrypto.Signerとして読み込みます
+func LoadSigner(filename string) (crypto.Signer, error) {
+	pemData, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file: %w", err)
+	}
+
+	return ParseSignerPEM(pemData)
+}
+
+// ParsePKIXPublicKeyPEM はPEMデータから任意の種類の公開鍵をパースします
+func ParsePKIXPublicKeyPEM(pemData []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, ErrInvalidPEMBlock
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	return pub, nil
+}
+
+// GenerateAndSaveSignerKeyPair は指定された種類の鍵ペアを生成し、Cloudflare Worker設定用ファイルも作成します
+func GenerateAndSaveSignerKeyPair(privateKeyFile, publicKeyFile, clientID, keyType string, bits int) error {
+	signer, err := GenerateSigner(keyType, bits)
+	if err != nil {
+		return err
+	}
+
+	// 秘密鍵を保存（パーミッション: 0600）
+	privateKeyPEM, err := MarshalPrivateKeyPEM(signer)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(privateKeyFile, privateKeyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to write private key file: %w", err)
+	}
+
+	// 公開鍵を保存（パーミッション: 0644）
+	publicKeyPEM, err := MarshalPublicKeyPEM(signer.Public())
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(publicKeyFile, publicKeyPEM, 0644); err != nil {
+		return fmt.Errorf("failed to write public key file: %w", err)
+	}
+
+	// Cloudflare Worker設定用ファイルを作成
+	return SaveCloudflareConfigForKey(publicKeyFile+".cloudflare.json", clientID, signer.Public())
+}
+
+// SaveCloudflareConfigForKey は任意の種類の公開鍵でCloudflare Worker用のワンライナーJSON設定を保存します
+func SaveCloudflareConfigForKey(filename, clientID string, publicKey crypto.PublicKey) error {
+	// 公開鍵をPEM形式にエンコード
+	publicKeyPEM, err := MarshalPublicKeyPEM(publicKey)
+	if err != nil {
+		return err
+	}
+
+	// 改行を\nに置換してワンライナー化
+	pemStr := string(publicKeyPEM)
+	pemStr = strings.ReplaceAll(pemStr, "\n", "\\n")
+	pemStr = strings.TrimSuffix(pemStr, "\\n") // 末尾の余分な\nを削除
+
+	// JSON形式で保存
+	jsonStr := fmt.Sprintf(`{"%s":"%s"}`, clientID, pemStr)
+
+	// ファイルに保存
+	if err := os.WriteFile(filename, []byte(jsonStr), 0644); err != nil {
+		return fmt.Errorf("failed to write cloudflare config file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package keygen
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateAndSaveSignerKeyPair(t *testing.T) {
+	tests := []struct {
+		keyType string
+		check   func(t *testing.T, key any)
+	}{
+		{keyType: KeyTypeRSA, check: func(t *testing.T, key any) {
+			if _, ok := key.(*rsa.PrivateKey); !ok {
+				t.Errorf("loaded key type = %T, want *rsa.PrivateKey", key)
+			}
+		}},
+		{keyType: KeyTypeECDSA, check: func(t *testing.T, key any) {
+			if _, ok := key.(*ecdsa.PrivateKey); !ok {
+				t.Errorf("loaded key type = %T, want *ecdsa.PrivateKey", key)
+			}
+		}},
+		{keyType: KeyTypeEd25519, check: func(t *testing.T, key any) {
+			if _, ok := key.(ed25519.PrivateKey); !ok {
+				t.Errorf("loaded key type = %T, want ed25519.PrivateKey", key)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.keyType, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			privateFile := filepath.Join(tmpDir, "private.pem")
+			publicFile := filepath.Join(tmpDir, "public.pem")
+
+			if err := GenerateAndSaveSignerKeyPair(privateFile, publicFile, "test-client", tt.keyType, 2048); err != nil {
+				t.Fatalf("GenerateAndSaveSignerKeyPair() error = %v", err)
+			}
+
+			signer, err := LoadSigner(privateFile)
+			if err != nil {
+				t.Fatalf("LoadSigner() error = %v", err)
+			}
+			tt.check(t, signer)
+
+			pemData, err := os.ReadFile(publicFile)
+			if err != nil {
+				t.Fatalf("failed to read public key: %v", err)
+			}
+			pub, err := ParsePKIXPublicKeyPEM(pemData)
+			if err != nil {
+				t.Fatalf("ParsePKIXPublicKeyPEM() error = %v", err)
+			}
+			if !signer.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(pub) {
+				t.Error("public key does not match private key")
+			}
+
+			// Cloudflare設定ファイルは有効なJSONでPEMを含む
+			configData, err := os.ReadFile(publicFile + ".cloudflare.json")
+			if err != nil {
+				t.Fatalf("failed to read cloudflare config: %v", err)
+			}
+			var config map[string]string
+			if err := json.Unmarshal(configData, &config); err != nil {
+				t.Fatalf("cloudflare config is not valid JSON: %v", err)
+			}
+			if _, err := ParsePKIXPublicKeyPEM([]byte(config["test-client"])); err != nil {
+				t.Errorf("cloudflare config PEM is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerateSigner_UnsupportedType(t *testing.T) {
+	if _, err := GenerateSigner("dsa", 0); err == nil {
+		t.Error("GenerateSigner(dsa) error = nil, want error")
+	}
+}
+
+func TestParseSignerPEM_SEC1(t *testing.T) {
+	signer, err := GenerateSigner(KeyTypeECDSA, 0)
+	if err != nil {
+		t.Fatalf("GenerateSigner() error = %v", err)
+	}
+
+	der, err := x509.MarshalECPrivateKey(signer.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatalf("failed to marshal ec key: %v", err)
+	}
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+
+	if _, err := ParseSignerPEM(pemData); err != nil {
+		t.Errorf("ParseSignerPEM() error = %v", err)
+	}
+	if _, err := ParseSignerPEM([]byte("invalid")); err == nil {
+		t.Error("ParseSignerPEM(invalid) error = nil, want error")
+	}
+}
@@ -0,0 +1,363 @@
+// Package metrics は外部依存なしの小さなメトリクスレジストリを提供します
+// 登録したカウンター・ゲージ・ヒストグラムはPrometheusのテキスト形式で公開できます
+//
+// nilの*Registryから取得したメトリクスはnilとなり、全ての操作が何もしません
+// これによりメトリクスを任意機能として扱えます
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets はレイテンシ（秒）向けのデフォルトのヒストグラムバケット
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	metricNameRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRe  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// kind はメトリクスの種類
+type kind string
+
+const (
+	kindCounter   kind = "counter"
+	kindGauge     kind = "gauge"
+	kindHistogram kind = "histogram"
+)
+
+// Registry はメトリクスの登録先
+// 複数のgoroutineから同時に使用できます
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry は空のレジストリを作成します
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// family は同じ名前のメトリクスの集合
+type family struct {
+	name       string
+	help       string
+	kind       kind
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series はラベル値の組み合わせごとの値
+type series struct {
+	labelValues []string
+	value       float64
+	fn          func() float64
+	counts      []uint64 // ヒストグラムのバケットごとの件数（累積しない）
+	sum         float64
+	count       uint64
+}
+
+// register は同名のメトリクスがあればそれを返し、なければ新しく登録します
+// 同名で種類・ラベル・バケットが異なる場合はpanicします
+func (r *Registry) register(name, help string, k kind, buckets []float64, labelNames []string) *family {
+	if !metricNameRe.MatchString(name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", name))
+	}
+	for _, label := range labelNames {
+		if !labelNameRe.MatchString(label) || label == "le" {
+			panic(fmt.Sprintf("metrics: invalid label name %q for %s", label, name))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		if f.kind != k || !slices.Equal(f.labelNames, labelNames) || !slices.Equal(f.buckets, buckets) {
+			panic(fmt.Sprintf("metrics: %s already registered with a different definition", name))
+		}
+		return f
+	}
+
+	f := &family{
+		name:       name,
+		help:       help,
+		kind:       k,
+		labelNames: slices.Clone(labelNames),
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+	r.families[name] = f
+	return f
+}
+
+// get はラベル値に対応する系列を返します（呼び出し側でf.muを保持すること）
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: slices.Clone(labelValues)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Counter は単調増加するカウンター
+type Counter struct {
+	f *family
+}
+
+// Counter はカウンターを登録して返します
+func (r *Registry) Counter(name, help string, labelNames ...string) *Counter {
+	if r == nil {
+		return nil
+	}
+	return &Counter{f: r.register(name, help, kindCounter, nil, labelNames)}
+}
+
+// Inc はカウンターを1増やします
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add はカウンターにvを加算します（負の値はpanic）
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if c == nil {
+		return
+	}
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot decrease", c.f.name))
+	}
+
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.get(labelValues).value += v
+}
+
+// Gauge は任意に増減する値
+type Gauge struct {
+	f *family
+}
+
+// Gauge はゲージを登録して返します
+func (r *Registry) Gauge(name, help string, labelNames ...string) *Gauge {
+	if r == nil {
+		return nil
+	}
+	return &Gauge{f: r.register(name, help, kindGauge, nil, labelNames)}
+}
+
+// Set はゲージの値を設定します
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	if g == nil {
+		return
+	}
+
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	s := g.f.get(labelValues)
+	s.value = v
+	s.fn = nil
+}
+
+// SetFunc は公開時に呼び出して値を求める関数を設定します
+func (g *Gauge) SetFunc(fn func() float64, labelValues ...string) {
+	if g == nil {
+		return
+	}
+
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	g.f.get(labelValues).fn = fn
+}
+
+// Delete はラベル値に対応する系列を削除します
+func (g *Gauge) Delete(labelValues ...string) {
+	if g == nil {
+		return
+	}
+
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	delete(g.f.series, strings.Join(labelValues, "\xff"))
+}
+
+// Histogram は観測値の分布
+type Histogram struct {
+	f *family
+}
+
+// Histogram はヒストグラムを登録して返します
+// bucketsがnilの場合はDefaultBucketsを使用します
+func (r *Registry) Histogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if r == nil {
+		return nil
+	}
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("metrics: buckets of %s must be sorted", name))
+	}
+	return &Histogram{f: r.register(name, help, kindHistogram, slices.Clone(buckets), labelNames)}
+}
+
+// Observe は値を1件記録します
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	if h == nil {
+		return
+	}
+
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	s := h.f.get(labelValues)
+	if i := sort.SearchFloat64s(h.f.buckets, v); i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+// Handler はPrometheusのテキスト形式でメトリクスを返すハンドラを返します
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// WriteText は全てのメトリクスをPrometheusのテキスト形式で書き出します
+// メトリクスは名前順、系列はラベル値順に出力されます
+func (r *Registry) WriteText(w io.Writer) error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+
+	var b strings.Builder
+	for _, f := range families {
+		f.write(&b)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// write は1つのメトリクスをテキスト形式で書き出します
+func (f *family) write(b *strings.Builder) {
+	f.mu.Lock()
+	all := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		clone := *s
+		clone.counts = slices.Clone(s.counts)
+		all = append(all, &clone)
+	}
+	f.mu.Unlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		return slices.Compare(all[i].labelValues, all[j].labelValues) < 0
+	})
+
+	fmt.Fprintf(b, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", f.name, f.kind)
+
+	for _, s := range all {
+		switch f.kind {
+		case kindHistogram:
+			var cumulative uint64
+			for i, upper := range f.buckets {
+				cumulative += s.counts[i]
+				writeSample(b, f.name+"_bucket", f.labelNames, s.labelValues, "le", formatFloat(upper), float64(cumulative))
+			}
+			writeSample(b, f.name+"_bucket", f.labelNames, s.labelValues, "le", "+Inf", float64(s.count))
+			writeSample(b, f.name+"_sum", f.labelNames, s.labelValues, "", "", s.sum)
+			writeSample(b, f.name+"_count", f.labelNames, s.labelValues, "", "", float64(s.count))
+		default:
+			value := s.value
+			if s.fn != nil {
+				// 関数は公開時に評価（ロック外で呼び出す）
+				value = s.fn()
+			}
+			writeSample(b, f.name, f.labelNames, s.labelValues, "", "", value)
+		}
+	}
+}
+
+// writeSample は1行のサンプルを書き出します
+// extraNameが空でない場合は追加のラベル（ヒストグラムのle）を付与します
+func writeSample(b *strings.Builder, name string, labelNames, labelValues []string, extraName, extraValue string, value float64) {
+	b.WriteString(name)
+
+	if len(labelNames) > 0 || extraName != "" {
+		b.WriteByte('{')
+		for i, label := range labelNames {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, `%s="%s"`, label, escapeLabelValue(labelValues[i]))
+		}
+		if extraName != "" {
+			if len(labelNames) > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, `%s="%s"`, extraName, extraValue)
+		}
+		b.WriteByte('}')
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(value))
+	b.WriteByte('\n')
+}
+
+// formatFloat はテキスト形式の数値表現を返します
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeHelp はHELP行のエスケープを行います
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// escapeLabelValue はラベル値のエスケープを行います
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.Counter("app_requests_total", "Total requests.", "method", "code")
+	requests.Inc("GET", "200")
+	requests.Inc("GET", "200")
+	requests.Add(3, "POST", "500")
+
+	inflight := r.Gauge("app_inflight", "In-flight requests.")
+	inflight.Set(2)
+
+	age := r.Gauge("app_age_seconds", "Age.", "name")
+	age.SetFunc(func() float64 { return 42 }, "a")
+
+	latency := r.Histogram("app_latency_seconds", "Latency.", []float64{0.1, 1}, "op")
+	latency.Observe(0.05, "read")
+	latency.Observe(0.5, "read")
+	latency.Observe(2, "read")
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+
+	want := `# HELP app_age_seconds Age.
+# TYPE app_age_seconds gauge
+app_age_seconds{name="a"} 42
+# HELP app_inflight In-flight requests.
+# TYPE app_inflight gauge
+app_inflight 2
+# HELP app_latency_seconds Latency.
+# TYPE app_latency_seconds histogram
+app_latency_seconds_bucket{op="read",le="0.1"} 1
+app_latency_seconds_bucket{op="read",le="1"} 2
+app_latency_seconds_bucket{op="read",le="+Inf"} 3
+app_latency_seconds_sum{op="read"} 2.55
+app_latency_seconds_count{op="read"} 3
+# HELP app_requests_total Total requests.
+# TYPE app_requests_total counter
+app_requests_total{method="GET",code="200"} 2
+app_requests_total{method="POST",code="500"} 3
+`
+	if got := b.String(); got != want {
+		t.Errorf("WriteText() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRegistry_EscapesLabelValues(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("escaped_total", "Line one\nline two.", "v").Inc("a\"b\\c\nd")
+
+	var b strings.Builder
+	r.WriteText(&b)
+
+	if !strings.Contains(b.String(), `# HELP escaped_total Line one\nline two.`) {
+		t.Errorf("help not escaped:\n%s", b.String())
+	}
+	if !strings.Contains(b.String(), `escaped_total{v="a\"b\\c\nd"} 1`) {
+		t.Errorf("label value not escaped:\n%s", b.String())
+	}
+}
+
+func TestRegistry_ReRegister(t *testing.T) {
+	r := NewRegistry()
+	first := r.Counter("shared_total", "Shared.", "id")
+	second := r.Counter("shared_total", "Shared.", "id")
+	first.Inc("a")
+	second.Inc("a")
+
+	var b strings.Builder
+	r.WriteText(&b)
+	if !strings.Contains(b.String(), `shared_total{id="a"} 2`) {
+		t.Errorf("re-registered counter not shared:\n%s", b.String())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for conflicting definition")
+		}
+	}()
+	r.Gauge("shared_total", "Shared.", "id")
+}
+
+func TestRegistry_PanicsOnWrongLabelCount(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("labels_total", "Labels.", "a", "b")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for wrong label count")
+		}
+	}()
+	c.Inc("only-one")
+}
+
+func TestNilRegistry(t *testing.T) {
+	var r *Registry
+
+	// nilのレジストリから取得したメトリクスは何もしない
+	r.Counter("noop_total", "Noop.").Inc()
+	r.Gauge("noop", "Noop.").Set(1)
+	r.Histogram("noop_seconds", "Noop.", nil).Observe(1)
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil || b.Len() != 0 {
+		t.Errorf("WriteText() = %q, %v; want empty", b.String(), err)
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("handler_total", "Handler.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	if !strings.Contains(string(body), "handler_total 1\n") {
+		t.Errorf("body = %q", body)
+	}
+}